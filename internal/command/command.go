@@ -1,96 +1,1093 @@
 package command
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
+
+	"sluhach/internal/config"
 
 	"sluhach/pkg/clip"
 	"sluhach/pkg/models"
 	"sluhach/pkg/notify"
+	"sluhach/pkg/speaker"
+	"sluhach/pkg/stats"
 	"sluhach/pkg/stt"
 
 	"charm.land/lipgloss/v2"
+	vosk "github.com/alphacep/vosk-api/go"
 	"github.com/charmbracelet/fang"
+	"github.com/charmbracelet/x/term"
 	"github.com/spf13/cobra"
 )
 
-const (
-	recordStarted  = "▶️ recording started"
-	recordFinished = "⏹️ recording finished"
-	copiedToClip   = "📋 text copied to clipboard"
-	listen         = "🎤 listening"
-)
+const (
+	recordStarted  = "▶️ recording started"
+	recordFinished = "⏹️ recording finished"
+	copiedToClip   = "📋 text copied to clipboard"
+	listen         = "🎤 listening"
+	noDisplay      = "⚠️ %s skipped: %v"
+	verifyOK       = "✅"
+	verifySkipped  = "➖"
+	verifyFailed   = "❌"
+	upToDate       = "✅ model is up to date:"
+	imported       = "📦 model imported:"
+	notInstalled   = "⚠️ model %s is not installed, run \"sluhach model load %s\""
+	askInstall     = "❓ model %s is not installed. Download it now? [y/N] "
+	installing     = "⬇️ installing model %s"
+	catalogCached  = "🗂️ cached catalog, updated %s ago (use --refresh to update)"
+	catalogStale   = "⚠️ repository unreachable, showing cached catalog from %s ago"
+	pruned         = "🗑️ removed %s (%s, last used %s)"
+	wouldPrune     = "🗑️ would remove %s (%s, last used %s)"
+	storageUsage   = "💾 models use %s of %s"
+	overQuota      = "⚠️ models still use %s of %s, the rest is kept"
+	noStats        = "📊 no statistics yet, they are collected while reco runs"
+	statsReset     = "📊 statistics reset"
+	enrolled       = "🗣️ speaker %s enrolled from %d phrases (%s of speech)"
+	noSpeakers     = "⚠️ no speakers enrolled with %s, run \"sluhach speaker enroll\""
+	skippedSample  = "⚠️ %s skipped: %v"
+	unknownSpeaker = "unknown"
+)
+
+// version задаётся при сборке:
+// -ldflags "-X sluhach/internal/command.version=1.2.0".
+var version string
+
+var (
+	ErrUsage  = errors.New("invalid usage")
+	ErrConfig = errors.New("config error")
+)
+
+type Command struct {
+	cmd      *cobra.Command
+	flags    rootFlags
+	config   *config.Config
+	stt      *stt.Speach2Text
+	manager  *models.Manager
+	stats    *stats.Store
+	speakers *speaker.Store
+}
+
+// rootFlags — глобальные флаги, общие для всех подкоманд.
+type rootFlags struct {
+	config   string
+	modelDir string
+	verbose  bool
+	quiet    bool
+	headless bool
+}
+
+type recoFlags struct {
+	profile     string
+	model       string
+	autoInstall string
+	wait        int
+	output      string
+	device      string
+	noPaste     bool
+	noNotify    bool
+}
+
+// resolve накладывает явно заданные флаги поверх конфига, профиля и окружения.
+func (f *recoFlags) resolve(c *cobra.Command, cfg *config.Config) error {
+	if c.Flags().Changed("profile") {
+		if err := cfg.UseProfile(f.profile); err != nil {
+			return err
+		}
+		if c.Flags().Changed("headless") {
+			cfg.Headless, _ = c.Flags().GetBool("headless")
+		}
+	}
+	if c.Flags().Changed("model") {
+		cfg.Model = f.model
+	}
+	if c.Flags().Changed("wait") {
+		// та же проверка, что у config set wait
+		if f.wait <= 0 {
+			return fmt.Errorf("wait must be positive")
+		}
+		cfg.Wait = f.wait
+	}
+	if c.Flags().Changed("output") {
+		if f.output != config.OutputText && f.output != config.OutputJSON {
+			return fmt.Errorf("output must be %q or %q", config.OutputText, config.OutputJSON)
+		}
+		cfg.Output = f.output
+	}
+	if c.Flags().Changed("device") {
+		cfg.Device = f.device
+	}
+	if c.Flags().Changed("no-paste") {
+		cfg.Clipboard = !f.noPaste
+	}
+	if c.Flags().Changed("no-notify") {
+		cfg.Notifications = !f.noNotify
+	}
+	if c.Flags().Changed("auto-install") {
+		if !slices.Contains(config.AutoInstallModes, f.autoInstall) {
+			return fmt.Errorf("auto-install must be one of %s", strings.Join(config.AutoInstallModes, ", "))
+		}
+		cfg.AutoInstall = f.autoInstall
+	}
+	return nil
+}
+
+func (cmd *Command) reco(flags *recoFlags) func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		if err := flags.resolve(c, cmd.config); err != nil {
+			return fmt.Errorf("%w: %w", ErrUsage, err)
+		}
+		cfg := cmd.config
+
+		cmd.debug(c, "model:", cmd.stt.Resolve(cfg.Model), "wait:", cfg.Wait, "device:", cfg.Device)
+		m, err := loadOrInstall(cmd, c, cfg.Model, cmd.stt.LoadModel)
+		if err != nil {
+			return err
+		}
+		defer m.Free()
+
+		if cfg.Output == config.OutputText {
+			cmd.info(c, listen, fmt.Sprintf("(waiting for %d seconds of silence to stop)", cfg.Wait))
+		}
+		if cfg.Notifications && cmd.display(c, "notification") {
+			if err := notify.Notify(recordStarted, listen); err != nil {
+				return err
+			}
+		}
+
+		out, err := cmd.stt.Recognize(m, cfg.Wait, cfg.Device)
+		cmd.debugStats(c)
+		if err != nil {
+			return err
+		}
+
+		if out != "" {
+			if cfg.Output == config.OutputJSON {
+				if err := json.NewEncoder(c.OutOrStdout()).Encode(map[string]string{
+					"model": cmd.stt.Resolve(cfg.Model),
+					"text":  out,
+				}); err != nil {
+					return fmt.Errorf("failed to encode result: %w", err)
+				}
+			} else {
+				fmt.Fprintln(c.OutOrStdout(), out)
+			}
+
+			if cfg.Clipboard && cmd.display(c, "clipboard") {
+				if err := clip.Clip(out); err != nil {
+					return err
+				}
+				if cfg.Output == config.OutputText {
+					cmd.info(c, copiedToClip)
+				}
+			}
+
+			if cfg.Notifications && cmd.display(c, "notification") {
+				if err := notify.Notify(recordFinished, out); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// loadOrInstall загружает модель через load, а отсутствующую сначала ставит
+// согласно auto_install.
+func loadOrInstall[T any](cmd *Command, c *cobra.Command, name string, load func(string) (T, error)) (T, error) {
+	m, err := load(name)
+	if errors.Is(err, stt.ErrModelNotFound) {
+		installed, _err := cmd.installMissing(c, name)
+		if _err != nil {
+			return m, _err
+		}
+		if installed {
+			return load(name)
+		}
+		model := cmd.stt.Resolve(name)
+		err = fmt.Errorf("%w (run \"sluhach model load %s\" or use --auto-install)", err, model)
+	}
+	return m, err
+}
+
+type transcribeFlags struct {
+	model       string
+	output      string
+	autoInstall string
+	speakers    bool
+	vectors     bool
+	maxDistance float64
+	channels    []int
+	mix         bool
+}
+
+// resolve накладывает явно заданные флаги поверх конфига.
+func (f *transcribeFlags) resolve(c *cobra.Command, cfg *config.Config) error {
+	if c.Flags().Changed("model") {
+		cfg.Model = f.model
+	}
+	if c.Flags().Changed("output") {
+		if f.output != config.OutputText && f.output != config.OutputJSON {
+			return fmt.Errorf("output must be %q or %q", config.OutputText, config.OutputJSON)
+		}
+		cfg.Output = f.output
+	}
+	if c.Flags().Changed("auto-install") {
+		if !slices.Contains(config.AutoInstallModes, f.autoInstall) {
+			return fmt.Errorf("auto-install must be one of %s", strings.Join(config.AutoInstallModes, ", "))
+		}
+		cfg.AutoInstall = f.autoInstall
+	}
+	if f.maxDistance <= 0 {
+		return fmt.Errorf("max-distance must be positive")
+	}
+	return nil
+}
+
+func (cmd *Command) transcribe(flags *transcribeFlags) func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		if err := flags.resolve(c, cmd.config); err != nil {
+			return fmt.Errorf("%w: %w", ErrUsage, err)
+		}
+		cfg := cmd.config
+
+		cmd.debug(c, "model:", cmd.stt.Resolve(cfg.Model), "file:", s[0])
+		m, err := loadOrInstall(cmd, c, cfg.Model, cmd.stt.LoadModel)
+		if err != nil {
+			return err
+		}
+		defer m.Free()
+
+		var (
+			spk      *vosk.VoskSpkModel
+			spkModel = cmd.stt.Resolve(cfg.SpeakerModel)
+			known    []speaker.Speaker
+		)
+		if flags.speakers || flags.vectors {
+			cmd.debug(c, "speaker model:", spkModel)
+			if spk, err = loadOrInstall(cmd, c, cfg.SpeakerModel, cmd.stt.LoadSpkModel); err != nil {
+				return err
+			}
+			defer spk.Free()
+		}
+		if flags.speakers {
+			if known, err = cmd.speakers.List(); err != nil {
+				return err
+			}
+			if !slices.ContainsFunc(known, func(sp speaker.Speaker) bool { return sp.Model == spkModel }) {
+				cmd.info(c, fmt.Sprintf(noSpeakers, spkModel))
+			}
+		}
+
+		segments, err := cmd.stt.Transcribe(m, s[0], stt.FileOptions{
+			Speakers: spk,
+			Channels: flags.channels,
+			Mix:      flags.mix,
+		})
+		cmd.debugStats(c)
+		if err != nil {
+			return err
+		}
+		for i := range segments {
+			seg := &segments[i]
+			if flags.speakers {
+				// короткой фразе Vosk вектор не считает — говорящий неизвестен
+				seg.Speaker = unknownSpeaker
+				if len(seg.Vector) > 0 {
+					name, distance := speaker.Match(known, spkModel, seg.Vector, flags.maxDistance)
+					cmd.debug(c, fmt.Sprintf("%s: %q at %.3f", timestamp(seg.Start), name, distance))
+					if name != "" {
+						seg.Speaker = name
+					}
+				}
+			}
+			if !flags.vectors {
+				seg.Vector, seg.Frames = nil, 0
+			}
+		}
+
+		if cfg.Output == config.OutputJSON {
+			if err := json.NewEncoder(c.OutOrStdout()).Encode(map[string]any{
+				"model":    cmd.stt.Resolve(cfg.Model),
+				"file":     s[0],
+				"segments": segments,
+			}); err != nil {
+				return fmt.Errorf("failed to encode result: %w", err)
+			}
+			return nil
+		}
+		for _, seg := range segments {
+			label := seg.Speaker
+			if seg.Channel > 0 {
+				label = strings.TrimSpace(fmt.Sprintf("ch%d %s", seg.Channel, label))
+			}
+			line := fmt.Sprintf("[%s - %s] ", timestamp(seg.Start), timestamp(seg.End))
+			if label != "" {
+				line += label + ": "
+			}
+			fmt.Fprintln(c.OutOrStdout(), line+seg.Text)
+		}
+		return nil
+	}
+}
+
+// timestamp печатает секунды от начала записи как 01:02.3 или 1:01:02.3.
+func timestamp(seconds float64) string {
+	tenths := int(seconds*10 + 0.5)
+	h, m, sec := tenths/36000, tenths/600%60, float64(tenths%600)/10
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%04.1f", h, m, sec)
+	}
+	return fmt.Sprintf("%02d:%04.1f", m, sec)
+}
+
+// speakerEnroll записывает голос по образцам: из каждой фразы берётся
+// x-vector, и все они усредняются.
+func (cmd *Command) speakerEnroll() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		name, files := s[0], s[1:]
+		if strings.TrimSpace(name) == "" || name == unknownSpeaker {
+			return fmt.Errorf("%w: invalid speaker name %q", ErrUsage, name)
+		}
+		cfg := cmd.config
+
+		m, err := loadOrInstall(cmd, c, cfg.Model, cmd.stt.LoadModel)
+		if err != nil {
+			return err
+		}
+		defer m.Free()
+		spk, err := loadOrInstall(cmd, c, cfg.SpeakerModel, cmd.stt.LoadSpkModel)
+		if err != nil {
+			return err
+		}
+		defer spk.Free()
+
+		var samples []speaker.Embedding
+		for _, file := range files {
+			// образец — один голос, каналы сводим
+			segments, err := cmd.stt.Transcribe(m, file, stt.FileOptions{Speakers: spk, Mix: true})
+			if errors.Is(err, stt.ErrNoSpeech) {
+				cmd.info(c, fmt.Sprintf(skippedSample, file, err))
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			for _, seg := range segments {
+				samples = append(samples, speaker.Embedding{Vector: seg.Vector, Frames: seg.Frames})
+			}
+		}
+
+		sp, err := cmd.speakers.Enroll(name, cmd.stt.Resolve(cfg.SpeakerModel), samples)
+		if errors.Is(err, speaker.ErrNoSamples) {
+			return fmt.Errorf("%w: %w", stt.ErrNoSpeech, err)
+		}
+		if err != nil {
+			return err
+		}
+		cmd.info(c, fmt.Sprintf(enrolled, sp.Name, sp.Samples, speech(sp.Frames)))
+		return nil
+	}
+}
+
+// speech переводит кадры x-vector (по 10 мс) в длительность речи.
+func speech(frames int) time.Duration {
+	return (time.Duration(frames) * 10 * time.Millisecond).Round(time.Second)
+}
+
+func (cmd *Command) speakerList() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		speakers, err := cmd.speakers.List()
+		if err != nil {
+			return err
+		}
+		var (
+			sb strings.Builder
+			w  = tabwriter.NewWriter(&sb, 1, 1, 1, ' ', 0)
+		)
+		fmt.Fprintf(w, "#\t%s\t%s\t%s\t%s\t%s", "Name", "Phrases", "Speech", "Model", "Enrolled")
+		for i, sp := range speakers {
+			fmt.Fprintf(
+				w,
+				"\n%d\t%s\t%d\t%s\t%s\t%s",
+				i+1,
+				sp.Name,
+				sp.Samples,
+				speech(sp.Frames),
+				sp.Model,
+				sp.EnrolledAt.Local().Format(time.DateOnly),
+			)
+		}
+		w.Flush()
+		c.Println(
+			lipgloss.NewStyle().
+				Padding(0, 1).
+				Render(sb.String()),
+		)
+		return nil
+	}
+}
+
+func (cmd *Command) speakerRemove() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		return cmd.speakers.Remove(s[0])
+	}
+}
+
+// installMissing ставит отсутствующую модель согласно auto_install:
+// always — сразу, ask — после подтверждения в терминале, never — никогда.
+// Без терминала ask равносилен never, чтобы скрипты не зависали на вопросе.
+func (cmd *Command) installMissing(c *cobra.Command, name string) (bool, error) {
+	model := cmd.stt.Resolve(name)
+	if filepath.IsAbs(model) {
+		return false, nil
+	}
+	switch cmd.config.AutoInstall {
+	case config.AutoInstallNever:
+		return false, nil
+	case config.AutoInstallAsk:
+		if !interactive() {
+			return false, nil
+		}
+		ok, err := confirm(c, fmt.Sprintf(askInstall, model))
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	cmd.info(c, fmt.Sprintf(installing, model))
+	if err := cmd.manager.Load(model, false); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// interactive сообщает, что можно задать вопрос: есть терминал и для
+// вопроса (stderr), и для ответа (stdin).
+func interactive() bool {
+	return term.IsTerminal(os.Stdin.Fd()) && term.IsTerminal(os.Stderr.Fd())
+}
+
+// confirm задаёт вопрос с ответом да/нет; по умолчанию — нет.
+func confirm(c *cobra.Command, question string) (bool, error) {
+	c.PrintErr(question)
+	answer, err := bufio.NewReader(c.InOrStdin()).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("failed to read answer: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes", "д", "да":
+		return true, nil
+	}
+	return false, nil
+}
+
+// display сообщает, можно ли обращаться к графической сессии. В режиме
+// headless компоненты молча отключаются, без сессии — с предупреждением.
+func (cmd *Command) display(c *cobra.Command, what string) bool {
+	if cmd.config.Headless {
+		return false
+	}
+	if _, err := cmd.config.Session(); err != nil {
+		if !cmd.config.Quiet {
+			c.PrintErrln(fmt.Sprintf(noDisplay, what, err))
+		}
+		return false
+	}
+	return true
+}
+
+// info печатает служебное сообщение, если не задан --quiet.
+func (cmd *Command) info(c *cobra.Command, a ...any) {
+	if !cmd.config.Quiet {
+		c.Println(a...)
+	}
+}
+
+// debug печатает отладочное сообщение в stderr, если задан --verbose.
+func (cmd *Command) debug(c *cobra.Command, a ...any) {
+	if cmd.config.Verbose {
+		c.PrintErrln(a...)
+	}
+}
+
+// debugStats сообщает в --verbose, что статистику моделей не удалось
+// сохранить; распознаванию это не мешает.
+func (cmd *Command) debugStats(c *cobra.Command) {
+	if cmd.stats.Err != nil {
+		cmd.debug(c, cmd.stats.Err)
+	}
+}
+
+// age печатает длительность с точностью, достаточной человеку: 45s, 12m, 3h, 2d.
+func age(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// usage помечает ошибки проверки аргументов как ErrUsage.
+func usage(fn cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(c *cobra.Command, args []string) error {
+		if err := fn(c, args); err != nil {
+			return fmt.Errorf("%w: %w", ErrUsage, err)
+		}
+		return nil
+	}
+}
+
+// setup загружает конфигурацию после разбора глобальных флагов и
+// создаёт зависящие от неё компоненты.
+func (cmd *Command) setup() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		_config, err := config.New(cmd.flags.config, cmd.flags.modelDir)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+		if c.Flags().Changed("headless") {
+			_config.Headless = cmd.flags.headless
+		}
+		_config.Verbose = cmd.flags.verbose
+		_config.Quiet = cmd.flags.quiet
+
+		_repos, err := repos(_config)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+
+		cmd.config = _config
+		cmd.stats = stats.New(filepath.Join(_config.ModelDir, stats.FileName))
+		cmd.speakers = speaker.New(filepath.Join(_config.ModelDir, speaker.FileName))
+		cmd.stt = stt.New(
+			_config.ModelDir,
+			stt.WithSearchPaths(_config.ModelPaths...),
+			stt.WithAliases(_config.Aliases),
+			stt.WithVerbose(_config.Verbose),
+			stt.WithRecorder(cmd.stats),
+		)
+		client, err := models.NewClient(models.ClientOptions{
+			ConnectTimeout: _config.ConnectTimeout,
+			ReadTimeout:    _config.ReadTimeout,
+			CABundle:       _config.CABundle,
+			Proxy:          _config.Proxy,
+		})
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+
+		// полоса загрузки только для человека у терминала
+		var progress models.Progress
+		if !_config.Quiet && term.IsTerminal(os.Stderr.Fd()) {
+			progress = newProgressBar(c.ErrOrStderr())
+		}
+		cmd.manager = models.New(
+			_config.ModelDir,
+			models.WithSearchPaths(_config.ModelPaths...),
+			models.WithCacheDir(_config.CacheDir),
+			models.WithChecksums(_config.Checksums),
+			models.WithIndexTTL(_config.CatalogTTL),
+			models.WithRepos(_repos...),
+			models.WithProgress(progress),
+			models.WithConcurrency(_config.DownloadConcurrency),
+			models.WithQuota(_config.Quota),
+			models.WithClient(client),
+			models.WithRetries(_config.Retries),
+			models.WithUserAgent(userAgent(_config.UserAgent)),
+		)
+
+		if err := cmd.manager.Cleanup(); err != nil {
+			cmd.debug(c, "cleanup:", err)
+		}
+
+		cmd.debug(c, "config:", _config.Path)
+		cmd.debug(c, "model dir:", _config.ModelDir)
+		cmd.debug(c, "model paths:", strings.Join(_config.ModelPaths, ", "))
+		cmd.debug(c, "cache dir:", _config.CacheDir)
+		return nil
+	}
+}
+
+// buildVersion возвращает версию сборки: из ldflags, иначе из сведений
+// модуля (go install ...@v1.2.0), иначе "dev".
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// userAgent переводит user_agent из конфига в заголовок; пустая строка
+// оставляет заголовки браузера.
+func userAgent(value string) string {
+	if value == config.UserAgentSluhach {
+		return "sluhach/" + buildVersion()
+	}
+	return value
+}
+
+// repos переводит репозитории из конфига в models.Repo, подставляя
+// переменные окружения в значения заголовков.
+func repos(cfg *config.Config) ([]models.Repo, error) {
+	var _repos []models.Repo
+	for _, r := range cfg.Repos {
+		repo := models.Repo{
+			Name:    r.Name,
+			URL:     r.URL,
+			Headers: make(map[string]string, len(r.Headers)),
+		}
+		for key, value := range r.Headers {
+			repo.Headers[key] = os.ExpandEnv(value)
+		}
+		if err := repo.Validate(); err != nil {
+			return nil, fmt.Errorf("repo %s: %w", r.Name, err)
+		}
+		_repos = append(_repos, repo)
+	}
+	return _repos, nil
+}
+
+func (cmd *Command) configGet() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		value, err := cmd.config.Get(s[0])
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrUsage, err)
+		}
+		fmt.Fprintln(c.OutOrStdout(), value)
+		return nil
+	}
+}
+
+func (cmd *Command) configSet() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		// неверное значение или не удалось сохранить файл — ошибка конфига
+		if err := cmd.config.Set(s[0], s[1]); err != nil {
+			return fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+		return nil
+	}
+}
+
+func (cmd *Command) configPath() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		fmt.Fprintln(c.OutOrStdout(), cmd.config.Path)
+		return nil
+	}
+}
+
+func (cmd *Command) configShow() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		fmt.Fprintln(c.OutOrStdout(), strings.Join(cmd.config.Show(), "\n"))
+		return nil
+	}
+}
+
+type loadFlags struct {
+	force  bool
+	update bool
+}
+
+func (cmd *Command) load(flags *loadFlags) func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		force := flags.force
+		if flags.update {
+			outdated, err := cmd.manager.Outdated(s[0])
+			if errors.Is(err, models.ErrModelNotFound) {
+				outdated, err = true, nil
+			}
+			if err != nil {
+				return err
+			}
+			if !outdated {
+				cmd.info(c, upToDate, s[0])
+				return nil
+			}
+			force = true
+		}
+		return cmd.manager.Load(s[0], force)
+	}
+}
+
+type importFlags struct {
+	name  string
+	link  bool
+	force bool
+}
+
+func (cmd *Command) importModel(flags *importFlags) func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		name, err := cmd.manager.Import(s[0], models.ImportOptions{
+			Name:  flags.name,
+			Link:  flags.link,
+			Force: flags.force,
+		})
+		if errors.Is(err, models.ErrInvalidName) {
+			return fmt.Errorf("%w: %w", ErrUsage, err)
+		}
+		if err != nil {
+			return err
+		}
+		cmd.info(c, imported, name)
+		return nil
+	}
+}
+
+type availFlags struct {
+	offline bool
+	refresh bool
+	lang    string
+	maxSize string
+	sort    string
+	search  string
+}
+
+func (cmd *Command) avail(flags *availFlags) func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		policy := models.CacheDefault
+		switch {
+		case flags.offline:
+			policy = models.CacheOffline
+		case flags.refresh:
+			policy = models.CacheRefresh
+		}
+		index, err := cmd.manager.Avail(policy)
+		if err != nil {
+			return err
+		}
+		switch {
+		case cmd.manager.Stale(index) && policy == models.CacheDefault:
+			cmd.info(c, fmt.Sprintf(catalogStale, age(index.Age())))
+		case index.Cached:
+			cmd.info(c, fmt.Sprintf(catalogCached, age(index.Age())))
+		}
+
+		filter := models.Filter{
+			Lang:   flags.lang,
+			Search: flags.search,
+			Sort:   flags.sort,
+		}
+		if flags.maxSize != "" {
+			if filter.MaxSize, err = models.ParseSize(flags.maxSize); err != nil {
+				return fmt.Errorf("%w: --max-size: %w", ErrUsage, err)
+			}
+		}
+		models, err := index.Filter(filter)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrUsage, err)
+		}
+		var (
+			sb strings.Builder
+			w  = tabwriter.NewWriter(&sb, 1, 1, 1, ' ', 0)
+		)
+		fmt.Fprintf(w, "#\t%s\t%s\t%s\t%s\t%s\t%s\t%s", "Code", "Lang", "Name", "Size", "WER", "License", "Desc")
+		for i, m := range models {
+			fmt.Fprintf(
+				w,
+				"\n%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
+				i+1,
+				m.LangCode,
+				m.Lang,
+				m.Name,
+				m.Size,
+				m.WER,
+				m.License,
+				m.Desc,
+			)
+		}
+		w.Flush()
+		c.Println(
+			lipgloss.NewStyle().
+				Padding(0, 1).
+				Render(sb.String()),
+		)
+		return nil
+	}
+}
+
+type repoFlags struct {
+	headers []string
+}
 
-type Command struct {
-	cmd     *cobra.Command
-	stt     *stt.Speach2Text
-	manager *models.Manager
+func (cmd *Command) repoAdd(flags *repoFlags) func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		repo := config.Repo{Name: s[0], URL: s[1]}
+		// относительный путь к локальному репозиторию делаем абсолютным,
+		// чтобы он не зависел от текущего каталога
+		if u, err := url.Parse(repo.URL); err == nil && u.Scheme == "" && !filepath.IsAbs(repo.URL) {
+			path, err := filepath.Abs(repo.URL)
+			if err != nil {
+				return fmt.Errorf("failed to resolve repo dir: %w", err)
+			}
+			repo.URL = path
+		}
+		for _, header := range flags.headers {
+			key, value, ok := strings.Cut(header, ":")
+			if !ok {
+				return fmt.Errorf("%w: header must look like \"Name: value\", got %q", ErrUsage, header)
+			}
+			if repo.Headers == nil {
+				repo.Headers = make(map[string]string)
+			}
+			repo.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		if err := (models.Repo{Name: repo.Name, URL: repo.URL, Headers: repo.Headers}).Validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrUsage, err)
+		}
+		return cmd.config.AddRepo(repo)
+	}
 }
 
-func (cmd *Command) reco(model *string, wait *int, noPaste *bool) func(*cobra.Command, []string) error {
+func (cmd *Command) repoList() func(*cobra.Command, []string) error {
 	return func(c *cobra.Command, s []string) error {
-		m, err := cmd.stt.LoadModel(*model)
-		if err != nil {
-			return err
+		var (
+			sb strings.Builder
+			w  = tabwriter.NewWriter(&sb, 1, 1, 1, ' ', 0)
+		)
+		fmt.Fprintf(w, "#\t%s\t%s\t%s", "Name", "URL", "Headers")
+		for i, r := range cmd.manager.Repos() {
+			// значения заголовков не печатаем: в них обычно токены
+			headers := make([]string, 0, len(r.Headers))
+			for key := range r.Headers {
+				headers = append(headers, key)
+			}
+			slices.Sort(headers)
+			fmt.Fprintf(
+				w,
+				"\n%d\t%s\t%s\t%s",
+				i+1,
+				r.Name,
+				r.URL,
+				strings.Join(headers, ", "),
+			)
 		}
-		defer m.Free()
+		w.Flush()
+		c.Println(
+			lipgloss.NewStyle().
+				Padding(0, 1).
+				Render(sb.String()),
+		)
+		return nil
+	}
+}
+
+func (cmd *Command) repoRemove() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		return cmd.config.RemoveRepo(s[0])
+	}
+}
 
-		c.Println(listen, fmt.Sprintf("(waiting for %d seconds of silence to stop)", *wait))
-		if err := notify.Notify(recordStarted, listen); err != nil {
+// defaultModel печатает модель по умолчанию или, с аргументом, меняет её.
+func (cmd *Command) defaultModel() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		if len(s) == 0 {
+			fmt.Fprintln(c.OutOrStdout(), cmd.config.Model)
+			return nil
+		}
+		if err := cmd.config.Set("model", s[0]); err != nil {
 			return err
 		}
+		cmd.warnNotInstalled(c, s[0])
+		return nil
+	}
+}
 
-		out, err := cmd.stt.Recognize(m, *wait)
-		if err != nil {
+// warnNotInstalled предупреждает, что имя или алиас ведёт к отсутствующей
+// модели; сохранить такое имя можно, скачать модель — позже. Алиасы берутся
+// из конфига: он мог только что измениться.
+func (cmd *Command) warnNotInstalled(c *cobra.Command, name string) {
+	model := name
+	if alias, ok := cmd.config.Aliases[name]; ok {
+		model = alias
+	}
+	if filepath.IsAbs(model) {
+		return
+	}
+	if ok, err := cmd.manager.Installed(model); err == nil && !ok {
+		cmd.info(c, fmt.Sprintf(notInstalled, model, model))
+	}
+}
+
+func (cmd *Command) aliasSet() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		if err := cmd.config.Set("aliases."+s[0], s[1]); err != nil {
 			return err
 		}
+		cmd.warnNotInstalled(c, s[1])
+		return nil
+	}
+}
 
-		if out != "" {
-			c.Println(out)
+func (cmd *Command) aliasList() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		var (
+			sb strings.Builder
+			w  = tabwriter.NewWriter(&sb, 1, 1, 1, ' ', 0)
+		)
+		fmt.Fprintf(w, "#\t%s\t%s", "Alias", "Model")
+		for i, name := range slices.Sorted(maps.Keys(cmd.config.Aliases)) {
+			fmt.Fprintf(w, "\n%d\t%s\t%s", i+1, name, cmd.config.Aliases[name])
+		}
+		w.Flush()
+		c.Println(
+			lipgloss.NewStyle().
+				Padding(0, 1).
+				Render(sb.String()),
+		)
+		return nil
+	}
+}
 
-			if !*noPaste {
-				if err := clip.Clip(out); err != nil {
-					return err
-				}
-				c.Println(copiedToClip)
-			}
+func (cmd *Command) aliasRemove() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		if _, ok := cmd.config.Aliases[s[0]]; !ok {
+			return fmt.Errorf("alias %q not found", s[0])
+		}
+		return cmd.config.Set("aliases."+s[0], "")
+	}
+}
 
-			if err := notify.Notify(recordFinished, out); err != nil {
-				return err
+func (cmd *Command) remove() func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		return cmd.manager.Remove(s[0])
+	}
+}
+
+type pruneFlags struct {
+	maxSize string
+	dryRun  bool
+}
+
+func (cmd *Command) prune(flags *pruneFlags) func(*cobra.Command, []string) error {
+	return func(c *cobra.Command, s []string) error {
+		limit := cmd.config.Quota
+		if flags.maxSize != "" {
+			size, err := models.ParseSize(flags.maxSize)
+			if err != nil {
+				return fmt.Errorf("%w: max-size: %w", ErrUsage, err)
 			}
+			limit = size
+		}
+		if limit <= 0 {
+			return fmt.Errorf("%w: no quota set, pass --max-size or run \"sluhach config set quota 10G\"", ErrUsage)
+		}
+
+		used, _, err := cmd.manager.Usage()
+		if err != nil {
+			return err
+		}
+		// модель по умолчанию нужна reco, её не удаляем
+		_pruned, err := cmd.manager.Prune(models.PruneOptions{
+			Limit:  limit,
+			Keep:   []string{cmd.stt.Resolve(cmd.config.Model)},
+			DryRun: flags.dryRun,
+		})
+		msg := pruned
+		if flags.dryRun {
+			msg = wouldPrune
 		}
+		for _, m := range _pruned {
+			cmd.info(c, fmt.Sprintf(msg, m.Name, m.Size, m.LastUsed.Local().Format(time.DateOnly)))
+			used -= m.SizeBytes
+		}
+		if err != nil {
+			return err
+		}
+		if used > limit {
+			cmd.info(c, fmt.Sprintf(overQuota, models.FormatSize(used), models.FormatSize(limit)))
+			return nil
+		}
+		cmd.info(c, fmt.Sprintf(storageUsage, models.FormatSize(used), models.FormatSize(limit)))
 		return nil
 	}
 }
 
-func (cmd *Command) load() func(*cobra.Command, []string) error {
-	return func(c *cobra.Command, s []string) error {
-		return cmd.manager.Load(s[0])
-	}
+type statsFlags struct {
+	reset bool
 }
 
-func (cmd *Command) avail() func(*cobra.Command, []string) error {
+// modelStats печатает накопленную статистику моделей или, с --reset,
+// сбрасывает её.
+func (cmd *Command) modelStats(flags *statsFlags) func(*cobra.Command, []string) error {
 	return func(c *cobra.Command, s []string) error {
-		models, err := cmd.manager.Avail()
+		var name string
+		if len(s) > 0 {
+			name = cmd.stt.Resolve(s[0])
+		}
+		if flags.reset {
+			if err := cmd.stats.Reset(name); err != nil {
+				return err
+			}
+			cmd.info(c, statsReset)
+			return nil
+		}
+
+		all, err := cmd.stats.All()
 		if err != nil {
 			return err
 		}
+		if name != "" {
+			all = slices.DeleteFunc(all, func(m stats.Model) bool { return m.Name != name })
+			if len(all) == 0 {
+				return fmt.Errorf("no stats for model %s", name)
+			}
+		}
+		if len(all) == 0 {
+			cmd.info(c, noStats)
+			return nil
+		}
+
+		// время использования — то же, что в model list и prune; у удалённых
+		// моделей его нет, и пустой каталог моделей тоже не ошибка
+		installed, _ := cmd.manager.List()
+		lastUsed := make(map[string]time.Time, len(installed))
+		for _, m := range installed {
+			lastUsed[m.Name] = m.LastUsed
+		}
+		slices.SortStableFunc(all, func(a, b stats.Model) int {
+			return lastUsed[b.Name].Compare(lastUsed[a.Name])
+		})
+
 		var (
 			sb strings.Builder
 			w  = tabwriter.NewWriter(&sb, 1, 1, 1, ' ', 0)
 		)
-		fmt.Fprintf(w, "#\t%s\t%s\t%s\t%s", "Lang", "Name", "Size", "Desc")
-		for i, m := range models {
+		fmt.Fprintf(w, "#\t%s\t%s\t%s\t%s\t%s\t%s\t%s", "Name", "Loads", "Avg load", "Runs", "Audio", "RTF", "Last used")
+		for i, m := range all {
+			used := "-"
+			if t := lastUsed[m.Name]; !t.IsZero() {
+				used = t.Local().Format(time.DateTime)
+			}
 			fmt.Fprintf(
 				w,
-				"\n%d\t%s\t%s\t%s\t%s",
+				"\n%d\t%s\t%d\t%s\t%d\t%s\t%.2f\t%s",
 				i+1,
-				m.Lang,
 				m.Name,
-				m.Size,
-				m.Desc,
+				m.Loads,
+				m.AvgLoad().Round(time.Millisecond),
+				m.Runs,
+				time.Duration(m.AudioSeconds*float64(time.Second)).Round(time.Second),
+				m.RTF(),
+				used,
 			)
 		}
 		w.Flush()
@@ -103,9 +1100,35 @@ func (cmd *Command) avail() func(*cobra.Command, []string) error {
 	}
 }
 
-func (cmd *Command) remove() func(*cobra.Command, []string) error {
+func (cmd *Command) verify() func(*cobra.Command, []string) error {
 	return func(c *cobra.Command, s []string) error {
-		return cmd.manager.Remove(s[0])
+		names := s
+		if len(names) == 0 {
+			models, err := cmd.manager.List()
+			if err != nil {
+				return err
+			}
+			for _, m := range models {
+				names = append(names, m.Name)
+			}
+		}
+
+		var failed error
+		for _, name := range names {
+			err := cmd.manager.Verify(name)
+			switch {
+			case err == nil:
+				c.Println(verifyOK, name)
+			case errors.Is(err, models.ErrNoManifest) && len(s) == 0:
+				cmd.info(c, verifySkipped, name, "(no manifest, installed manually or by an older version)")
+			default:
+				c.PrintErrln(verifyFailed, name+":", err)
+				if failed == nil {
+					failed = err
+				}
+			}
+		}
+		return failed
 	}
 }
 
@@ -119,13 +1142,44 @@ func (cmd *Command) list() func(*cobra.Command, []string) error {
 			sb strings.Builder
 			w  = tabwriter.NewWriter(&sb, 1, 1, 1, ' ', 0)
 		)
-		fmt.Fprintf(w, "#\t%s\t%s", "Name", "Path")
+		// алиасы по имени модели, чтобы показать их рядом с моделью
+		aliases := make(map[string][]string)
+		for _, alias := range slices.Sorted(maps.Keys(cmd.config.Aliases)) {
+			model := cmd.config.Aliases[alias]
+			aliases[model] = append(aliases[model], alias)
+		}
+		defaultModel := cmd.stt.Resolve(cmd.config.Model)
+
+		fmt.Fprintf(w, "#\t \t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s", "Name", "Aliases", "Lang", "Size", "Rate", "Rescore", "Installed", "Status", "Path")
 		for i, m := range models {
+			var mark string
+			if m.Name == defaultModel || m.Path == defaultModel {
+				mark = "*"
+			}
+			var rate, installed string
+			if m.SampleRate > 0 {
+				rate = strconv.Itoa(m.SampleRate)
+			}
+			if !m.InstalledAt.IsZero() {
+				installed = m.InstalledAt.Local().Format(time.DateOnly)
+			}
+			status := "ok"
+			if m.Invalid != "" {
+				status = "invalid: " + m.Invalid
+			}
 			fmt.Fprintf(
 				w,
-				"\n%d\t%s\t%s",
+				"\n%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
 				i+1,
+				mark,
 				m.Name,
+				strings.Join(aliases[m.Name], ","),
+				m.LangCode,
+				m.Size,
+				rate,
+				strings.Join(m.Rescore, ","),
+				installed,
+				status,
 				m.Path,
 			)
 		}
@@ -139,13 +1193,8 @@ func (cmd *Command) list() func(*cobra.Command, []string) error {
 	}
 }
 
-func New(
-	_stt *stt.Speach2Text,
-	_manager *models.Manager,
-) *Command {
+func New() *Command {
 	_command := &Command{
-		stt:     _stt,
-		manager: _manager,
 		cmd: &cobra.Command{
 			Use:   "sluhach",
 			Short: "Simple speech-to-text tool",
@@ -161,11 +1210,36 @@ Main commands:
   sluhach reco
       Record from microphone and recognize speech.
 
+  sluhach transcribe [file.wav]
+      Recognize a recorded file by channel, optionally labeling speakers.
+
+  sluhach speaker ...
+      Enroll speakers for transcribe --speakers.
+
   sluhach model ...
-      Manage speech recognition models (list, load, remove, avail).`,
+      Manage speech recognition models (list, load, remove, avail).
+
+  sluhach config ...
+      Show and change persistent settings.
+
+Global flags (--config, --model-dir, --verbose, --quiet, --headless) are
+accepted by every command.
+
+Exit codes:
+  0  success
+  1  unexpected error
+  2  invalid usage or configuration
+  3  model not found
+  4  audio device unavailable
+  5  no speech detected
+  6  network failure
+  7  checksum mismatch
+  8  clipboard or notifications unavailable
+  9  not enough disk space or model quota exceeded`,
 			Example: `  sluhach reco
   sluhach reco -m vosk-model-small-ru-0.22
-  sluhach model list
+  sluhach --model-dir /srv/models model list
+  sluhach --config ./sluhach.toml -q reco
   sluhach model avail`,
 			RunE: func(c *cobra.Command, args []string) error {
 				return c.Help()
@@ -173,11 +1247,33 @@ Main commands:
 		},
 	}
 
-	var (
-		modelpath string
-		wait      int
-		noPaste   bool
+	_command.cmd.PersistentPreRunE = _command.setup()
+	_command.cmd.SetFlagErrorFunc(func(c *cobra.Command, err error) error {
+		return fmt.Errorf("%w: %w", ErrUsage, err)
+	})
+	_command.cmd.PersistentFlags().StringVar(
+		&_command.flags.config, "config", "",
+		"Config file (default $XDG_CONFIG_HOME/sluhach/config.toml)",
+	)
+	_command.cmd.PersistentFlags().StringVar(
+		&_command.flags.modelDir, "model-dir", "",
+		"Directory for installed models (default $XDG_DATA_HOME/sluhach)",
+	)
+	_command.cmd.PersistentFlags().BoolVarP(
+		&_command.flags.verbose, "verbose", "v", false,
+		"Print debug information",
 	)
+	_command.cmd.PersistentFlags().BoolVarP(
+		&_command.flags.quiet, "quiet", "q", false,
+		"Print only results and errors",
+	)
+	_command.cmd.PersistentFlags().BoolVar(
+		&_command.flags.headless, "headless", false,
+		"Run without a graphical session: no clipboard and no notifications",
+	)
+	_command.cmd.MarkFlagsMutuallyExclusive("verbose", "quiet")
+
+	var flags recoFlags
 
 	reco := &cobra.Command{
 		Use:     "reco (alias:r)",
@@ -186,10 +1282,21 @@ Main commands:
 		Long: `Records speech from the microphone and recognizes it using the selected model.
 
 By default:
-  - uses the "vosk-model-small-ru-0.22" model
+  - uses the default model (see "sluhach model default"), initially
+    "vosk-model-small-ru-0.22"
   - stops recording after several seconds of silence (see the --wait flag)
   - prints the recognized text to the terminal and copies it to the clipboard
 
+Without a graphical session (cron, containers, SSH) the clipboard and
+notifications are skipped with a warning; --headless skips them silently.
+
+If the model is not installed, reco asks whether to download it (only when
+run in a terminal), or downloads it right away with --auto-install; use
+--auto-install=never in scripts to fail immediately.
+
+Defaults can be changed in the config file (see "sluhach config"), with
+SLUHACH_* environment variables or with a named profile. Flags always win.
+
 Examples:
   sluhach reco
       Record from microphone, recognize and copy the text.
@@ -201,21 +1308,119 @@ Examples:
       Wait up to 8 seconds of silence before stopping recording.
 
   sluhach reco --no-paste
-      Do not copy the result to the clipboard, only print it to the terminal.`,
+      Do not copy the result to the clipboard, only print it to the terminal.
+
+  sluhach reco -p en
+      Use settings from the "en" profile of the config file.`,
 		Example: `  sluhach reco
   sluhach reco -m vosk-model-small-ru-0.22
   sluhach reco -m vosk-model-en-us-0.22 -w 8
-  sluhach reco --no-paste`,
-		RunE: _command.reco(
-			&modelpath, &wait, &noPaste,
-		),
+  sluhach reco --no-paste
+  sluhach reco -p en -o json`,
+		RunE: _command.reco(&flags),
 	}
-	reco.Flags().StringVarP(&modelpath, "model", "m", "vosk-model-small-ru-0.22", "Model name")
-	reco.Flags().IntVarP(&wait, "wait", "w", 5, "Seconds of silence before stop")
-	reco.Flags().BoolVarP(&noPaste, "no-paste", "", false, "Do not copy recognized text to clipboard")
+	reco.Flags().StringVarP(&flags.profile, "profile", "p", "", "Config profile to use")
+	reco.Flags().StringVarP(&flags.model, "model", "m", "", "Model name, alias or path (default from \"sluhach model default\")")
+	reco.Flags().IntVarP(&flags.wait, "wait", "w", config.DefaultWait, "Seconds of silence before stop")
+	reco.Flags().StringVarP(&flags.output, "output", "o", config.DefaultOutput, "Output format (text|json)")
+	reco.Flags().StringVarP(&flags.device, "device", "d", "", "Input device name (default input device if empty)")
+	reco.Flags().BoolVarP(&flags.noPaste, "no-paste", "", false, "Do not copy recognized text to clipboard")
+	reco.Flags().BoolVarP(&flags.noNotify, "no-notify", "", false, "Do not show desktop notifications")
+	reco.Flags().StringVar(&flags.autoInstall, "auto-install", config.DefaultAutoInstall, "Install a missing model: "+strings.Join(config.AutoInstallModes, "|")+" (bare flag means always)")
+	reco.Flags().Lookup("auto-install").NoOptDefVal = config.AutoInstallAlways
 
 	_command.cmd.AddCommand(reco)
 
+	var transcribeFlags transcribeFlags
+	transcribe := &cobra.Command{
+		Use:     "transcribe [file.wav]",
+		Aliases: []string{"t"},
+		Short:   "Recognize speech in a WAV file",
+		Long: `Recognize a recorded WAV file (16-bit PCM, any sample rate) and print its
+phrases with start and end times.
+
+Every channel of a stereo or multi-channel file is recognized on its own
+and the phrases are merged into one timeline labeled ch1, ch2...: in a call
+recording each side usually has its own channel. --channel limits
+recognition to some channels, --mix mixes them down to one instead.
+
+With --speakers every phrase is labeled with the closest speaker enrolled
+with "sluhach speaker enroll"; a phrase too short to get a voice print, or
+one that is farther than --max-distance from everyone, is labeled
+"unknown". --vectors adds the raw speaker vectors (x-vectors) to the JSON
+output. Both need the speaker model from the speaker_model config key
+(` + config.DefaultSpeakerModel + ` by default), installed like any other
+model.
+
+A missing model is installed according to --auto-install, as in reco.`,
+		Args: usage(cobra.ExactArgs(1)),
+		Example: `  sluhach transcribe call.wav
+  sluhach transcribe -m vosk-model-en-us-0.22 -o json meeting.wav
+  sluhach transcribe --speakers interview.wav
+  sluhach transcribe --channel 2 call.wav
+  sluhach transcribe --mix -o json stereo-mic.wav
+  sluhach transcribe --vectors -o json sample.wav`,
+		RunE: _command.transcribe(&transcribeFlags),
+	}
+	transcribe.Flags().StringVarP(&transcribeFlags.model, "model", "m", "", "Model name, alias or path (default from \"sluhach model default\")")
+	transcribe.Flags().StringVarP(&transcribeFlags.output, "output", "o", config.DefaultOutput, "Output format (text|json)")
+	transcribe.Flags().BoolVarP(&transcribeFlags.speakers, "speakers", "s", false, "Label phrases with enrolled speakers")
+	transcribe.Flags().BoolVar(&transcribeFlags.vectors, "vectors", false, "Include speaker vectors in JSON output")
+	transcribe.Flags().Float64Var(&transcribeFlags.maxDistance, "max-distance", speaker.DefaultMaxDistance, "Cosine distance beyond which a speaker is unknown")
+	transcribe.Flags().IntSliceVarP(&transcribeFlags.channels, "channel", "c", nil, "Recognize only these channels, from 1 (default all)")
+	transcribe.Flags().BoolVar(&transcribeFlags.mix, "mix", false, "Mix all channels into one before recognition")
+	transcribe.MarkFlagsMutuallyExclusive("channel", "mix")
+	transcribe.Flags().StringVar(&transcribeFlags.autoInstall, "auto-install", config.DefaultAutoInstall, "Install a missing model: "+strings.Join(config.AutoInstallModes, "|")+" (bare flag means always)")
+	transcribe.Flags().Lookup("auto-install").NoOptDefVal = config.AutoInstallAlways
+
+	_command.cmd.AddCommand(transcribe)
+
+	speakerCmd := &cobra.Command{
+		Use:   "speaker",
+		Short: "Manage enrolled speakers",
+		Long: `Manage voices that "sluhach transcribe --speakers" can recognize.
+
+A speaker is enrolled from one or more WAV recordings of their voice: every
+phrase yields a voice print (x-vector) computed by the speaker model
+(speaker_model config key), and their average is stored in .speakers.json
+in the models directory. Enrolling the same name again adds the new
+samples to the old ones; a minute of clear speech is usually enough.
+
+Voice prints depend on the speaker model, so after changing speaker_model
+speakers have to be enrolled again.`,
+		Example: `  sluhach speaker enroll alice alice-1.wav alice-2.wav
+  sluhach speaker list
+  sluhach speaker remove alice`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	speakerCmd.AddCommand([]*cobra.Command{
+		{
+			Use:     "enroll [name] [file.wav...]",
+			Short:   "Enroll a speaker from voice samples",
+			Args:    usage(cobra.MinimumNArgs(2)),
+			Example: "  sluhach speaker enroll alice alice-1.wav alice-2.wav",
+			RunE:    _command.speakerEnroll(),
+		},
+		{
+			Use:     "list",
+			Short:   "List enrolled speakers",
+			Args:    usage(cobra.NoArgs),
+			Example: "  sluhach speaker list",
+			RunE:    _command.speakerList(),
+		},
+		{
+			Use:     "remove [name]",
+			Short:   "Remove an enrolled speaker",
+			Args:    usage(cobra.ExactArgs(1)),
+			Example: "  sluhach speaker remove alice",
+			RunE:    _command.speakerRemove(),
+		},
+	}...,
+	)
+	_command.cmd.AddCommand(speakerCmd)
+
 	model := &cobra.Command{
 		Use:     "model (alias:m)",
 		Aliases: []string{"m"},
@@ -228,73 +1433,416 @@ Examples:
   sluhach model load [name]
       Download and unpack a model with the given name into the models directory.
 
+  sluhach model import [archive|dir]
+      Install a model from a local archive or directory.
+
   sluhach model remove [name]
       Remove a previously downloaded model from the models directory.
 
   sluhach model avail
-      Show models that are available for download from the remote repository.`,
+      Show models that are available for download from the remote repository.
+
+  sluhach model verify [name]
+      Check installed model files against the manifest written at install time.
+
+  sluhach model default [name]
+      Show or set the model used by reco when -m is not given.
+
+  sluhach model alias ...
+      Manage short names for models (ru, en-small).
+
+  sluhach model repo ...
+      Manage repositories (mirrors) that models are downloaded from.`,
 		Example: `  sluhach model list
   sluhach model load vosk-model-small-ru-0.22
   sluhach model remove vosk-model-small-ru-0.22
-  sluhach model avail`,
+  sluhach model avail
+  sluhach model repo list`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	var loadFlags loadFlags
+	load := &cobra.Command{
+		Use:   "load [name]",
+		Short: "Download a model",
+		Long: `Download and unpack a Vosk model by name.
+
+The model archive <name>.zip is fetched from the first configured repository
+that has it (see "sluhach model repo") and extracted into the local models
+directory. If the model is already present, the command
+returns an error: use --force to reinstall it, or --update to reinstall it
+only when the archive it was installed from has changed (size, ETag or
+Last-Modified differ from the ones recorded at install time).
+
+The archive is checked against a SHA-256 pinned in the config
+(checksums.<name>) or published next to it as <archive>.sha256; on mismatch
+nothing is installed. A manifest with hashes of all model files is written
+for "sluhach model verify".
+
+Before downloading, the archive size is checked against the free disk space
+and the quota config key; if the model would not fit the command exits with
+code 9 (see "sluhach model prune").`,
+		Args: usage(cobra.ExactArgs(1)),
+		Example: `  sluhach model load vosk-model-small-ru-0.22
+  sluhach model load vosk-model-en-us-0.22
+  sluhach model load --update vosk-model-small-ru-0.22`,
+		RunE: _command.load(&loadFlags),
+	}
+	load.Flags().BoolVarP(&loadFlags.force, "force", "f", false, "Reinstall the model if it is already installed")
+	load.Flags().BoolVarP(&loadFlags.update, "update", "u", false, "Reinstall the model only if it changed on the server")
+	load.MarkFlagsMutuallyExclusive("force", "update")
+
+	alias := &cobra.Command{
+		Use:   "alias",
+		Short: "Manage short model names",
+		Long: `Manage short names for models, such as "ru" or "en-small".
+
+An alias can be used wherever reco expects a model: "sluhach reco -m ru",
+the "model" config key or "sluhach model default ru". Aliases are stored in
+the config file (aliases.<alias>) and win over a model directory with the
+same name.`,
+		Example: `  sluhach model alias set ru vosk-model-small-ru-0.22
+  sluhach model alias set en-small vosk-model-small-en-us-0.15
+  sluhach model alias list
+  sluhach model alias remove en-small`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	alias.AddCommand([]*cobra.Command{
+		{
+			Use:     "set [alias] [model]",
+			Short:   "Create or change an alias",
+			Args:    usage(cobra.ExactArgs(2)),
+			Example: "  sluhach model alias set ru vosk-model-small-ru-0.22",
+			RunE:    _command.aliasSet(),
+		},
+		{
+			Use:     "list",
+			Short:   "List aliases",
+			Args:    usage(cobra.NoArgs),
+			Example: "  sluhach model alias list",
+			RunE:    _command.aliasList(),
+		},
+		{
+			Use:     "remove [alias]",
+			Short:   "Remove an alias",
+			Args:    usage(cobra.ExactArgs(1)),
+			Example: "  sluhach model alias remove ru",
+			RunE:    _command.aliasRemove(),
+		},
+	}...,
+	)
+
+	var importFlags importFlags
+	importCmd := &cobra.Command{
+		Use:   "import [archive|dir]",
+		Short: "Install a model from a local archive or directory",
+		Long: `Install a model from a local archive (zip, tar, tar.gz, tar.xz or tar.zst,
+detected from the contents) or an unpacked model directory, without going
+online.
+
+The archive is extracted and checked exactly like a downloaded one: a model
+with an invalid layout is never installed, and a SHA-256 pinned in the config
+(checksums.<name>) or a <archive>.sha256 file next to the archive is
+verified. The model name is taken from the top-level directory inside the
+archive, the archive or directory name, or --name.
+
+A directory is copied into the models directory; with --link a symbolic
+link to it is created instead, and the directory itself is left untouched
+(no manifest is written, so "sluhach model verify" skips it).`,
+		Args: usage(cobra.ExactArgs(1)),
+		Example: `  sluhach model import /media/usb/vosk-model-small-ru-0.22.zip
+  sluhach model import ./custom-model.tar.gz --name custom-ru
+  sluhach model import --link /srv/models/vosk-model-ru-0.42`,
+		RunE: _command.importModel(&importFlags),
+	}
+	importCmd.Flags().StringVarP(&importFlags.name, "name", "n", "", "Install the model under this name")
+	importCmd.Flags().BoolVarP(&importFlags.link, "link", "l", false, "Link the directory instead of copying it")
+	importCmd.Flags().BoolVarP(&importFlags.force, "force", "f", false, "Replace the model if it is already installed")
+
+	var pruneFlags pruneFlags
+	prune := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove least recently used models to fit the quota",
+		Long: `Remove models from the models directory, least recently used first, until
+their total size fits the quota (the "quota" config key) or --max-size.
+
+A model counts as used when reco loads it; a model that was never used
+counts from its install date. The default model, models in shared
+directories and models linked with "model import --link" are never
+removed.
+
+With a quota set, "model load" and "model import" refuse to install a model
+that would not fit and exit with code 9; run this command to make room.`,
+		Args: usage(cobra.NoArgs),
+		Example: `  sluhach model prune --dry-run
+  sluhach model prune --max-size 5G
+  sluhach config set quota 10G && sluhach model prune`,
+		RunE: _command.prune(&pruneFlags),
+	}
+	prune.Flags().StringVar(&pruneFlags.maxSize, "max-size", "", "Prune down to this size instead of the quota (5G, 800M)")
+	prune.Flags().BoolVarP(&pruneFlags.dryRun, "dry-run", "n", false, "Only show what would be removed")
+
+	var statsFlags statsFlags
+	statsCmd := &cobra.Command{
+		Use:   "stats [name]",
+		Short: "Show model usage and recognition speed",
+		Long: `Show statistics collected while reco runs: how many times each model was
+loaded and how long that took on average, how many recognitions it served,
+how much audio it processed and when it was last used (the same time
+"model list" shows and "model prune" goes by).
+
+RTF (real-time factor) is the time Vosk spent on recognition divided by the
+length of the audio: below 1 the model keeps up with speech, and the lower
+the better. Compare it between models to pick one that suits your machine.
+
+Statistics are kept in .stats.json in the models directory; --reset clears
+them for one model or, without a name, for all.`,
+		Args: usage(cobra.MaximumNArgs(1)),
+		Example: `  sluhach model stats
+  sluhach model stats vosk-model-small-ru-0.22
+  sluhach model stats --reset`,
+		RunE: _command.modelStats(&statsFlags),
+	}
+	statsCmd.Flags().BoolVar(&statsFlags.reset, "reset", false, "Clear collected statistics")
+
+	var availFlags availFlags
+	avail := &cobra.Command{
+		Use:   "avail",
+		Short: "List models available for download",
+		Long: `Show models that are available for download from the remote repository.
+
+The list is fetched from the first reachable repository and includes language code,
+language, name, size, word error rate, license and a short description for
+each model.
+
+The parsed list is cached in the models directory and reused while it is
+younger than catalog_ttl (24h by default, see "sluhach config"). If the
+server cannot be reached the cached list is shown whatever its age.`,
+		Example: `  sluhach model avail
+  sluhach model avail --offline
+  sluhach model avail --refresh
+  sluhach model avail --lang en --max-size 100M --sort size
+  sluhach model avail --search lgraph`,
+		RunE: _command.avail(&availFlags),
+	}
+	avail.Flags().StringVarP(&availFlags.lang, "lang", "l", "", "Only models for a language code (en, en-us) or name (English)")
+	avail.Flags().StringVar(&availFlags.maxSize, "max-size", "", "Only models not larger than this size (45M, 1.8G)")
+	avail.Flags().StringVarP(&availFlags.sort, "sort", "s", "", "Sort by "+strings.Join(models.SortKeys, "|"))
+	avail.Flags().StringVar(&availFlags.search, "search", "", "Only models whose name or description contains the text")
+	avail.Flags().BoolVar(&availFlags.offline, "offline", false, "Use only the cached catalog, never go online")
+	avail.Flags().BoolVar(&availFlags.refresh, "refresh", false, "Fetch the catalog even if the cache is fresh")
+	avail.MarkFlagsMutuallyExclusive("offline", "refresh")
+
+	repo := &cobra.Command{
+		Use:   "repo",
+		Short: "Manage model repositories",
+		Long: `Manage repositories that models and the catalog are downloaded from.
+
+A repository is an HTTP(S) mirror, a local directory or a file:// URL holding
+<model>.zip archives and, optionally, <model>.zip.sha256 checksums. The
+catalog of an HTTP repository is its index page (as on alphacephei.com) or
+a JSON file served with a JSON content type; a local repository may hold an
+index.json, otherwise every *.zip in it is listed.
+
+Repositories are tried in the order they were added: the next one is used
+only if the model is missing or the repository is unreachable. While none
+are configured the official repository ` + models.DefaultRepo.URL + ` is used;
+once you add one, add the official repository too if you want it as
+a fallback.
+
+Header values may reference environment variables ($TOKEN), so secrets do
+not have to be stored in the config file.`,
+		Example: `  sluhach model repo add mirror https://models.example.com/vosk -H 'Authorization: Bearer $VOSK_TOKEN'
+  sluhach model repo add local /srv/vosk-models
+  sluhach model repo add official https://alphacephei.com/vosk/models
+  sluhach model repo list
+  sluhach model repo remove mirror`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return cmd.Help()
 		},
 	}
 
+	var repoFlags repoFlags
+	repoAdd := &cobra.Command{
+		Use:   "add [name] [url]",
+		Short: "Add a repository to the end of the list",
+		Args:  usage(cobra.ExactArgs(2)),
+		Example: `  sluhach model repo add mirror https://models.example.com/vosk
+  sluhach model repo add nas file:///mnt/nas/vosk -H 'X-Api-Key: $NAS_KEY'`,
+		RunE: _command.repoAdd(&repoFlags),
+	}
+	repoAdd.Flags().StringArrayVarP(&repoFlags.headers, "header", "H", nil, `Header sent with every request, "Name: value" (repeatable)`)
+
+	repo.AddCommand([]*cobra.Command{
+		repoAdd,
+		{
+			Use:     "list",
+			Short:   "List repositories in the order they are tried",
+			Args:    usage(cobra.NoArgs),
+			Example: "  sluhach model repo list",
+			RunE:    _command.repoList(),
+		},
+		{
+			Use:     "remove [name]",
+			Short:   "Remove a repository",
+			Args:    usage(cobra.ExactArgs(1)),
+			Example: "  sluhach model repo remove mirror",
+			RunE:    _command.repoRemove(),
+		},
+	}...,
+	)
+
 	model.AddCommand([]*cobra.Command{
 		{
 			Use:   "list",
 			Short: "List installed models",
 			Long: `List models that are already downloaded and available locally.
 
-This command inspects the models directory and prints all models that can be
-used for recognition without additional downloads.`,
+This command inspects the models directories and prints every installed
+model with its language code, disk size, sample rate (from conf/), extra
+rescoring graphs (rnnlm, rescore), install date and status. A model with
+an incomplete layout is shown as invalid together with what is missing;
+files lying next to the models are ignored.`,
 			Example: `  sluhach model list`,
 			RunE:    _command.list(),
 		},
-		{
-			Use:   "load [name]",
-			Short: "Download a model",
-			Long: `Download and unpack a Vosk model by name.
-
-The model archive is fetched from the configured base URL and extracted into
-the local models directory. If the model is already present, the command
-returns an error.`,
-			Args: cobra.ExactArgs(1),
-			Example: `  sluhach model load vosk-model-small-ru-0.22
-  sluhach model load vosk-model-en-us-0.22`,
-			RunE: _command.load(),
-		},
+		load,
+		importCmd,
 		{
 			Use:   "remove [name]",
 			Short: "Remove a model",
 			Long: `Remove a previously downloaded model from the local models directory.
 
 This does not affect any remote resources, only local files are deleted.`,
-			Args:    cobra.ExactArgs(1),
+			Args:    usage(cobra.ExactArgs(1)),
 			Example: "  sluhach model remove vosk-model-small-ru-0.22",
 			RunE:    _command.remove(),
 		},
+		prune,
+		statsCmd,
+		{
+			Use:   "verify [name]",
+			Short: "Verify installed model files",
+			Long: `Re-hash the files of installed models and compare them with the manifest
+written when the model was installed.
+
+Without a name every installed model is checked; models installed without
+a manifest are skipped. The command fails with exit code 7 if any file is
+missing, modified or unexpected.`,
+			Args: usage(cobra.MaximumNArgs(1)),
+			Example: `  sluhach model verify
+  sluhach model verify vosk-model-small-ru-0.22`,
+			RunE: _command.verify(),
+		},
+		avail,
+		repo,
+		alias,
 		{
-			Use:   "avail",
-			Short: "List models available for download",
-			Long: `Show models that are available for download from the remote repository.
-
-The list is fetched from the remote server and includes language, name, size
-and a short description for each model.`,
-			Example: "  sluhach model avail",
-			RunE:    _command.avail(),
+			Use:   "default [name]",
+			Short: "Show or set the default model",
+			Long: `Show the model reco uses when -m is not given or, with an argument, store
+a new default in the config file (the "model" key). The name may be an
+alias. A selected profile or SLUHACH_MODEL still overrides the stored value.`,
+			Args: usage(cobra.MaximumNArgs(1)),
+			Example: `  sluhach model default
+  sluhach model default vosk-model-en-us-0.22
+  sluhach model default ru`,
+			RunE: _command.defaultModel(),
 		},
 	}...,
 	)
 	_command.cmd.AddCommand(model)
 
+	conf := &cobra.Command{
+		Use:   "config",
+		Short: "Manage settings",
+		Long: `Show and change persistent settings.
+
+Settings are stored in $XDG_CONFIG_HOME/sluhach/config.toml (config.yaml is
+also supported). Values are resolved in the following order, later ones win:
+defaults, config file, selected profile, SLUHACH_* environment variables,
+command line flags.
+
+Keys:
+  model          default model name or alias
+  wait           seconds of silence before recording stops
+  output         output format: text or json
+  clipboard      copy recognized text to the clipboard (true/false)
+  notifications  show desktop notifications (true/false)
+  device         input device name, empty for the default device
+  headless       never use the clipboard and notifications (true/false)
+  catalog_ttl    how long "model avail" trusts its cached catalog (e.g. 24h)
+  auto_install   install a missing model in reco: ask, always or never
+  download_concurrency
+                 parallel range requests per model download (1-16, default 1)
+  quota          total size limit for installed models (e.g. 10G, 0 for none)
+  connect_timeout
+                 timeout for connecting to a model repository (default 30s)
+  read_timeout   abort a download when no data arrives for this long (default 1m)
+  retries        retries after network errors, 429 and 5xx (0-10, default 3)
+  ca_bundle      PEM file with extra CA certificates for HTTPS repositories
+  proxy          proxy URL (http, https, socks5), "none", or empty for
+                 HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+  user_agent     empty for browser-like headers, "sluhach" for
+                 sluhach/<version>, or any custom string
+  speaker_model  speaker model for transcribe --speakers
+                 (default ` + config.DefaultSpeakerModel + `)
+  profile        profile used when --profile is not given
+
+Profile values are set with profiles.<name>.<key>, model aliases with
+aliases.<alias> and pinned archive hashes with checksums.<model>.`,
+		Example: `  sluhach config show
+  sluhach config set wait 8
+  sluhach config set profiles.en.model vosk-model-en-us-0.22
+  SLUHACH_WAIT=3 sluhach config get wait`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	conf.AddCommand([]*cobra.Command{
+		{
+			Use:     "get [key]",
+			Short:   "Print the effective value of a key",
+			Args:    usage(cobra.ExactArgs(1)),
+			Example: "  sluhach config get model",
+			RunE:    _command.configGet(),
+		},
+		{
+			Use:   "set [key] [value]",
+			Short: "Store a value in the config file",
+			Args:  usage(cobra.ExactArgs(2)),
+			Example: `  sluhach config set model vosk-model-en-us-0.22
+  sluhach config set profiles.en.wait 8`,
+			RunE: _command.configSet(),
+		},
+		{
+			Use:     "path",
+			Short:   "Print the config file path",
+			Args:    usage(cobra.NoArgs),
+			Example: "  sluhach config path",
+			RunE:    _command.configPath(),
+		},
+		{
+			Use:     "show",
+			Short:   "Print all effective settings",
+			Args:    usage(cobra.NoArgs),
+			Example: "  sluhach config show",
+			RunE:    _command.configShow(),
+		},
+	}...,
+	)
+	_command.cmd.AddCommand(conf)
+
 	return _command
 }
 
 func (cmd *Command) Execute(ctx context.Context) error {
-	if err := fang.Execute(ctx, cmd.cmd); err != nil {
+	if err := fang.Execute(ctx, cmd.cmd, fang.WithVersion(buildVersion())); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,100 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"sluhach/pkg/models"
+
+	"charm.land/lipgloss/v2"
+)
+
+const (
+	progressWidth = 30
+	// перерисовываем не чаще, чем нужно глазу
+	progressInterval = 100 * time.Millisecond
+)
+
+var (
+	progressFilled = lipgloss.NewStyle().Foreground(lipgloss.Color("#7D56F4"))
+	progressEmpty  = lipgloss.NewStyle().Foreground(lipgloss.Color("#3C3C3C"))
+)
+
+// progressBar рисует полосу загрузки со скоростью и оставшимся временем.
+// Создаётся, только если stderr — терминал и не задан --quiet.
+type progressBar struct {
+	w       io.Writer
+	name    string
+	offset  int64
+	total   int64
+	current int64
+	started time.Time
+	drawn   time.Time
+}
+
+func newProgressBar(w io.Writer) *progressBar {
+	return &progressBar{w: w}
+}
+
+func (p *progressBar) Start(name string, offset, total int64) {
+	p.name = name
+	p.offset = offset
+	p.total = total
+	p.current = offset
+	p.started = time.Now()
+	p.draw()
+}
+
+func (p *progressBar) Update(downloaded int64) {
+	p.current = downloaded
+	if time.Since(p.drawn) >= progressInterval {
+		p.draw()
+	}
+}
+
+func (p *progressBar) Finish(err error) {
+	p.draw()
+	fmt.Fprintln(p.w)
+}
+
+func (p *progressBar) draw() {
+	p.drawn = time.Now()
+
+	// скорость считаем только по байтам этой попытки, без докачанного ранее
+	var speed float64
+	if elapsed := time.Since(p.started).Seconds(); elapsed > 0 {
+		speed = float64(p.current-p.offset) / elapsed
+	}
+	rate := models.FormatSize(int64(speed)) + "/s"
+
+	var line string
+	if p.total > 0 {
+		ratio := min(float64(p.current)/float64(p.total), 1)
+		filled := int(ratio * progressWidth)
+		bar := progressFilled.Render(strings.Repeat("█", filled)) +
+			progressEmpty.Render(strings.Repeat("░", progressWidth-filled))
+		eta := "--:--"
+		if speed > 0 {
+			eta = clock(time.Duration(float64(p.total-p.current) / speed * float64(time.Second)))
+		}
+		line = fmt.Sprintf(
+			"⬇️ %s %s %5.1f%% %s/%s %s ETA %s",
+			p.name, bar, ratio*100, models.FormatSize(p.current), models.FormatSize(p.total), rate, eta,
+		)
+	} else {
+		line = fmt.Sprintf("⬇️ %s %s %s", p.name, models.FormatSize(p.current), rate)
+	}
+	// \r и очистка до конца строки: новая строка может быть короче старой
+	lipgloss.Fprint(p.w, "\r"+line+"\x1b[K")
+}
+
+// clock печатает длительность как 1:02:03 или 02:03.
+func clock(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%02d:%02d", s/60, s%60)
+}
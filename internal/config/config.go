@@ -1,53 +1,967 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
 	"sluhach/pkg/fs"
+	"sluhach/pkg/models"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	dataDir   = "~/.local/share"
+	dataDirs  = "/usr/local/share:/usr/share"
+	configDir = "~/.config"
+	envPrefix = "SLUHACH_"
+)
+
+const (
+	DefaultModel       = "vosk-model-small-ru-0.22"
+	DefaultWait        = 5
+	DefaultOutput      = OutputText
+	DefaultCatalogTTL  = 24 * time.Hour
+	DefaultAutoInstall = AutoInstallAsk
+	// DefaultSpeakerModel — модель спикеров для transcribe --speakers.
+	DefaultSpeakerModel = "vosk-model-spk-0.4"
+	// DefaultDownloadConcurrency — загрузка архива одним потоком.
+	DefaultDownloadConcurrency = 1
 )
 
+// UserAgentSluhach в user_agent означает "sluhach/<версия>"; пустое
+// значение — заголовки браузера, как раньше.
+const UserAgentSluhach = "sluhach"
+
+// MaxRetries ограничивает retries: с удвоением паузы больше уже не нужно.
+const MaxRetries = 10
+
+// MaxDownloadConcurrency ограничивает число одновременных запросов к
+// серверу моделей: больше соединений скорость уже не прибавляют.
+const MaxDownloadConcurrency = 16
+
 const (
-	modelDir = "~/.local/share/sluhach"
+	OutputText = "text"
+	OutputJSON = "json"
 )
 
+// Значения auto_install: что делать, если модели для reco нет.
+const (
+	// AutoInstallAsk — спросить в терминале; без терминала — как never.
+	AutoInstallAsk    = "ask"
+	AutoInstallAlways = "always"
+	AutoInstallNever  = "never"
+)
+
+// AutoInstallModes — допустимые значения auto_install.
+var AutoInstallModes = []string{AutoInstallAsk, AutoInstallAlways, AutoInstallNever}
+
+// порядок важен: первый найденный файл считается конфигом,
+// если ни одного нет — создаётся config.toml
+var configNames = []string{"config.toml", "config.yaml", "config.yml"}
+
+// Keys — ключи, которые можно задать в конфиге, профиле и окружении.
+var Keys = []string{
+	"model",
+	"wait",
+	"output",
+	"clipboard",
+	"notifications",
+	"device",
+	"headless",
+	"catalog_ttl",
+	"auto_install",
+	"download_concurrency",
+	"quota",
+	"connect_timeout",
+	"read_timeout",
+	"retries",
+	"ca_bundle",
+	"proxy",
+	"user_agent",
+	"speaker_model",
+}
+
+// ErrNoDisplay — нет графической сессии для буфера обмена и уведомлений.
+var ErrNoDisplay = errors.New("no graphical session (XDG_SESSION_TYPE, WAYLAND_DISPLAY and DISPLAY are not set)")
+
+// Settings — значения из одного источника (файл, профиль, окружение).
+// nil означает, что значение в этом источнике не задано.
+type Settings struct {
+	Model         *string `toml:"model,omitempty" yaml:"model,omitempty"`
+	Wait          *int    `toml:"wait,omitempty" yaml:"wait,omitempty"`
+	Output        *string `toml:"output,omitempty" yaml:"output,omitempty"`
+	Clipboard     *bool   `toml:"clipboard,omitempty" yaml:"clipboard,omitempty"`
+	Notifications *bool   `toml:"notifications,omitempty" yaml:"notifications,omitempty"`
+	Device        *string `toml:"device,omitempty" yaml:"device,omitempty"`
+	Headless      *bool   `toml:"headless,omitempty" yaml:"headless,omitempty"`
+	CatalogTTL    *string `toml:"catalog_ttl,omitempty" yaml:"catalog_ttl,omitempty"`
+	AutoInstall   *string `toml:"auto_install,omitempty" yaml:"auto_install,omitempty"`
+	// DownloadConcurrency — сколько кусков архива модели качать одновременно.
+	DownloadConcurrency *int `toml:"download_concurrency,omitempty" yaml:"download_concurrency,omitempty"`
+	// Quota — предел общего размера моделей: 10G, 0 — без предела.
+	Quota *string `toml:"quota,omitempty" yaml:"quota,omitempty"`
+	// Настройки HTTP для загрузки моделей и каталога.
+	ConnectTimeout *string `toml:"connect_timeout,omitempty" yaml:"connect_timeout,omitempty"`
+	ReadTimeout    *string `toml:"read_timeout,omitempty" yaml:"read_timeout,omitempty"`
+	Retries        *int    `toml:"retries,omitempty" yaml:"retries,omitempty"`
+	CABundle       *string `toml:"ca_bundle,omitempty" yaml:"ca_bundle,omitempty"`
+	Proxy          *string `toml:"proxy,omitempty" yaml:"proxy,omitempty"`
+	UserAgent      *string `toml:"user_agent,omitempty" yaml:"user_agent,omitempty"`
+	// SpeakerModel — модель спикеров (vosk-model-spk) для опознания голосов.
+	SpeakerModel *string `toml:"speaker_model,omitempty" yaml:"speaker_model,omitempty"`
+}
+
+// Repo — репозиторий моделей: HTTP‑зеркало, локальный каталог или file:// URL.
+// В значениях Headers подставляются переменные окружения ($TOKEN), чтобы
+// не хранить секреты в файле.
+type Repo struct {
+	Name    string            `toml:"name" yaml:"name"`
+	URL     string            `toml:"url" yaml:"url"`
+	Headers map[string]string `toml:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// File — содержимое файла конфигурации.
+type File struct {
+	Settings `yaml:",inline"`
+	Profile  *string             `toml:"profile,omitempty" yaml:"profile,omitempty"`
+	Profiles map[string]Settings `toml:"profiles,omitempty" yaml:"profiles,omitempty"`
+	// Checksums — закреплённые пользователем SHA-256 архивов моделей.
+	Checksums map[string]string `toml:"checksums,omitempty" yaml:"checksums,omitempty"`
+	// Repos — репозитории моделей в порядке перебора.
+	Repos []Repo `toml:"repos,omitempty" yaml:"repos,omitempty"`
+	// Aliases — короткие имена моделей: ru = "vosk-model-small-ru-0.22".
+	Aliases map[string]string `toml:"aliases,omitempty" yaml:"aliases,omitempty"`
+}
+
 type Config struct {
-	SessionType string
-	ModelDir    string
+	// ModelDir — каталог, куда ставятся и откуда удаляются модели.
+	ModelDir string
+	// ModelPaths — каталоги поиска моделей, ModelDir всегда первый.
+	ModelPaths []string
+	// CacheDir — каталог для недокачанных архивов и прочих временных данных.
+	CacheDir string
+	Path     string
+
+	Model         string
+	Wait          int
+	Output        string
+	Clipboard     bool
+	Notifications bool
+	Device        string
+	Headless      bool
+	CatalogTTL    time.Duration
+	AutoInstall   string
+	Profile       string
+
+	DownloadConcurrency int
+	// Quota — предел общего размера моделей в ModelDir в байтах, 0 — без него.
+	Quota int64
+
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	Retries        int
+	// CABundle — абсолютный путь к PEM‑файлу, пусто — только системные CA.
+	CABundle string
+	// Proxy — URL прокси, models.ProxyNone или пусто (из окружения).
+	Proxy     string
+	UserAgent string
+
+	SpeakerModel string
+
+	Verbose bool
+	Quiet   bool
+
+	// Checksums — SHA-256 архивов моделей по имени модели.
+	Checksums map[string]string
+	// Repos — репозитории моделей; пустой список — официальный каталог.
+	Repos []Repo
+	// Aliases — короткие имена моделей.
+	Aliases map[string]string
+
+	file *File
+	env  Settings
 }
 
-func getModelDir() (string, error) {
+func expandHome(path string) (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get user home dir: %w", err)
 	}
 
-	expanded := modelDir
-	if len(modelDir) >= 2 && modelDir[:2] == "~/" {
-		expanded = filepath.Join(home, modelDir[2:])
+	expanded := path
+	if len(path) >= 2 && path[:2] == "~/" {
+		expanded = filepath.Join(home, path[2:])
+	}
+
+	abs, err := filepath.Abs(expanded)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for %s: %w", path, err)
 	}
+	return abs, nil
+}
 
-	_modelDir, err := filepath.Abs(expanded)
+// getModelDir: флаг --model-dir, SLUHACH_MODEL_DIR, затем
+// $XDG_DATA_HOME/sluhach, затем ~/.local/share/sluhach.
+func getModelDir(dir string) (string, error) {
+	if dir == "" {
+		dir = os.Getenv(envPrefix + "MODEL_DIR")
+	}
+	if dir == "" {
+		base := os.Getenv("XDG_DATA_HOME")
+		if base == "" {
+			base = dataDir
+		}
+		dir = filepath.Join(base, "sluhach")
+	}
+	_modelDir, err := expandHome(dir)
 	if err != nil {
 		return "", fmt.Errorf("failed to get absolute path for modelDir: %w", err)
 	}
 	return _modelDir, nil
 }
 
-func New() (*Config, error) {
-	_sessionType := os.Getenv("XDG_SESSION_TYPE")
-	if _sessionType == "" {
-		return nil, fmt.Errorf("XDG_SESSION_TYPE is not set")
+// getModelPaths возвращает каталоги поиска после ModelDir: сначала
+// SLUHACH_MODEL_PATH, затем общесистемные <XDG_DATA_DIRS>/sluhach/models.
+func getModelPaths(modelDir string) ([]string, error) {
+	var dirs []string
+	for _, dir := range filepath.SplitList(os.Getenv(envPrefix + "MODEL_PATH")) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	system := os.Getenv("XDG_DATA_DIRS")
+	if system == "" {
+		system = dataDirs
+	}
+	for _, dir := range filepath.SplitList(system) {
+		if dir != "" {
+			dirs = append(dirs, filepath.Join(dir, "sluhach", "models"))
+		}
+	}
+
+	paths := []string{modelDir}
+	for _, dir := range dirs {
+		abs, err := expandHome(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(paths, abs) {
+			paths = append(paths, abs)
+		}
+	}
+	return paths, nil
+}
+
+// getCacheDir: $XDG_CACHE_HOME/sluhach, затем ~/.cache/sluhach.
+func getCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache dir: %w", err)
+	}
+	return filepath.Join(dir, "sluhach"), nil
+}
+
+// getConfigPath: флаг --config, SLUHACH_CONFIG, затем
+// $XDG_CONFIG_HOME/sluhach/config.*.
+func getConfigPath(path string) (string, error) {
+	if path == "" {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+	if path != "" {
+		return expandHome(path)
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		_dir, err := expandHome(configDir)
+		if err != nil {
+			return "", err
+		}
+		dir = _dir
+	}
+	dir = filepath.Join(dir, "sluhach")
+	for _, name := range configNames {
+		path := filepath.Join(dir, name)
+		if err := fs.Exists(path); err == nil {
+			return path, nil
+		}
+	}
+	return filepath.Join(dir, configNames[0]), nil
+}
+
+func isYAML(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func readFile(path string) (*File, error) {
+	file := &File{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if isYAML(path) {
+		err = yaml.Unmarshal(data, file)
+	} else {
+		err = toml.Unmarshal(data, file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if err := file.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return file, nil
+}
+
+func writeFile(path string, file *File) error {
+	var (
+		data []byte
+		err  error
+	)
+	if isYAML(path) {
+		data, err = yaml.Marshal(file)
+	} else {
+		data, err = toml.Marshal(file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := fs.CreateDirs(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+func readEnv() (Settings, string, error) {
+	var env Settings
+	for _, key := range Keys {
+		value, ok := os.LookupEnv(envPrefix + strings.ToUpper(key))
+		if !ok {
+			continue
+		}
+		if err := env.Set(key, value); err != nil {
+			return env, "", fmt.Errorf("invalid %s%s: %w", envPrefix, strings.ToUpper(key), err)
+		}
+	}
+	return env, os.Getenv(envPrefix + "PROFILE"), nil
+}
+
+// validate прогоняет значения из файла через те же проверки, что и Set.
+func (s *Settings) validate() error {
+	var tmp Settings
+	for _, key := range Keys {
+		if value, ok := s.Get(key); ok {
+			if err := tmp.Set(key, value); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (f *File) validate() error {
+	if err := f.Settings.validate(); err != nil {
+		return err
+	}
+	for name, profile := range f.Profiles {
+		if err := profile.validate(); err != nil {
+			return fmt.Errorf("profiles.%s.%w", name, err)
+		}
+	}
+	for name, model := range f.Aliases {
+		if err := validAlias(name); err != nil {
+			return fmt.Errorf("aliases.%s: %w", name, err)
+		}
+		if model == "" {
+			return fmt.Errorf("aliases.%s: model name is required", name)
+		}
+	}
+	for i, repo := range f.Repos {
+		if repo.Name == "" || repo.URL == "" {
+			return fmt.Errorf("repos[%d]: name and url are required", i)
+		}
+		if slices.ContainsFunc(f.Repos[:i], func(r Repo) bool { return r.Name == repo.Name }) {
+			return fmt.Errorf("repos[%d]: duplicate repo %q", i, repo.Name)
+		}
+	}
+	return nil
+}
+
+// Get возвращает значение ключа, если оно задано в этом источнике.
+func (s *Settings) Get(key string) (string, bool) {
+	switch key {
+	case "model":
+		if s.Model != nil {
+			return *s.Model, true
+		}
+	case "wait":
+		if s.Wait != nil {
+			return strconv.Itoa(*s.Wait), true
+		}
+	case "output":
+		if s.Output != nil {
+			return *s.Output, true
+		}
+	case "clipboard":
+		if s.Clipboard != nil {
+			return strconv.FormatBool(*s.Clipboard), true
+		}
+	case "notifications":
+		if s.Notifications != nil {
+			return strconv.FormatBool(*s.Notifications), true
+		}
+	case "device":
+		if s.Device != nil {
+			return *s.Device, true
+		}
+	case "headless":
+		if s.Headless != nil {
+			return strconv.FormatBool(*s.Headless), true
+		}
+	case "catalog_ttl":
+		if s.CatalogTTL != nil {
+			return *s.CatalogTTL, true
+		}
+	case "auto_install":
+		if s.AutoInstall != nil {
+			return *s.AutoInstall, true
+		}
+	case "download_concurrency":
+		if s.DownloadConcurrency != nil {
+			return strconv.Itoa(*s.DownloadConcurrency), true
+		}
+	case "quota":
+		if s.Quota != nil {
+			return *s.Quota, true
+		}
+	case "connect_timeout":
+		if s.ConnectTimeout != nil {
+			return *s.ConnectTimeout, true
+		}
+	case "read_timeout":
+		if s.ReadTimeout != nil {
+			return *s.ReadTimeout, true
+		}
+	case "retries":
+		if s.Retries != nil {
+			return strconv.Itoa(*s.Retries), true
+		}
+	case "ca_bundle":
+		if s.CABundle != nil {
+			return *s.CABundle, true
+		}
+	case "proxy":
+		if s.Proxy != nil {
+			return *s.Proxy, true
+		}
+	case "user_agent":
+		if s.UserAgent != nil {
+			return *s.UserAgent, true
+		}
+	case "speaker_model":
+		if s.SpeakerModel != nil {
+			return *s.SpeakerModel, true
+		}
+	}
+	return "", false
+}
+
+// Set разбирает и проверяет строковое значение ключа.
+func (s *Settings) Set(key, value string) error {
+	switch key {
+	case "model":
+		s.Model = &value
+	case "wait":
+		wait, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("wait must be a number: %w", err)
+		}
+		if wait <= 0 {
+			return fmt.Errorf("wait must be positive")
+		}
+		s.Wait = &wait
+	case "output":
+		if value != OutputText && value != OutputJSON {
+			return fmt.Errorf("output must be %q or %q", OutputText, OutputJSON)
+		}
+		s.Output = &value
+	case "clipboard":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("clipboard must be true or false: %w", err)
+		}
+		s.Clipboard = &b
+	case "notifications":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("notifications must be true or false: %w", err)
+		}
+		s.Notifications = &b
+	case "device":
+		s.Device = &value
+	case "headless":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("headless must be true or false: %w", err)
+		}
+		s.Headless = &b
+	case "catalog_ttl":
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("catalog_ttl must be a duration like 12h or 30m: %w", err)
+		}
+		s.CatalogTTL = &value
+	case "auto_install":
+		if !slices.Contains(AutoInstallModes, value) {
+			return fmt.Errorf("auto_install must be one of %s", strings.Join(AutoInstallModes, ", "))
+		}
+		s.AutoInstall = &value
+	case "download_concurrency":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("download_concurrency must be a number: %w", err)
+		}
+		if n < 1 || n > MaxDownloadConcurrency {
+			return fmt.Errorf("download_concurrency must be between 1 and %d", MaxDownloadConcurrency)
+		}
+		s.DownloadConcurrency = &n
+	case "quota":
+		if _, err := models.ParseSize(value); err != nil {
+			return fmt.Errorf("quota must be a size like 10G or 0: %w", err)
+		}
+		s.Quota = &value
+	case "connect_timeout", "read_timeout":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a duration like 30s or 2m: %w", key, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("%s must be positive", key)
+		}
+		if key == "connect_timeout" {
+			s.ConnectTimeout = &value
+		} else {
+			s.ReadTimeout = &value
+		}
+	case "retries":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("retries must be a number: %w", err)
+		}
+		if n < 0 || n > MaxRetries {
+			return fmt.Errorf("retries must be between 0 and %d", MaxRetries)
+		}
+		s.Retries = &n
+	case "ca_bundle":
+		s.CABundle = &value
+	case "proxy":
+		if value != "" && value != models.ProxyNone {
+			if _, err := models.ParseProxy(value); err != nil {
+				return err
+			}
+		}
+		s.Proxy = &value
+	case "user_agent":
+		s.UserAgent = &value
+	case "speaker_model":
+		if value == "" {
+			return errors.New("speaker_model must not be empty")
+		}
+		s.SpeakerModel = &value
+	default:
+		return fmt.Errorf("unknown config key %q", key)
+	}
+	return nil
+}
+
+func (c *Config) apply(s Settings) {
+	if s.Model != nil {
+		c.Model = *s.Model
+	}
+	if s.Wait != nil {
+		c.Wait = *s.Wait
+	}
+	if s.Output != nil {
+		c.Output = *s.Output
+	}
+	if s.Clipboard != nil {
+		c.Clipboard = *s.Clipboard
+	}
+	if s.Notifications != nil {
+		c.Notifications = *s.Notifications
+	}
+	if s.Device != nil {
+		c.Device = *s.Device
+	}
+	if s.Headless != nil {
+		c.Headless = *s.Headless
+	}
+	if s.CatalogTTL != nil {
+		// значение уже проверено в Set или при чтении файла
+		c.CatalogTTL, _ = time.ParseDuration(*s.CatalogTTL)
+	}
+	if s.AutoInstall != nil {
+		c.AutoInstall = *s.AutoInstall
+	}
+	if s.DownloadConcurrency != nil {
+		c.DownloadConcurrency = *s.DownloadConcurrency
+	}
+	if s.Quota != nil {
+		c.Quota, _ = models.ParseSize(*s.Quota)
+	}
+	if s.ConnectTimeout != nil {
+		c.ConnectTimeout, _ = time.ParseDuration(*s.ConnectTimeout)
+	}
+	if s.ReadTimeout != nil {
+		c.ReadTimeout, _ = time.ParseDuration(*s.ReadTimeout)
+	}
+	if s.Retries != nil {
+		c.Retries = *s.Retries
+	}
+	if s.CABundle != nil {
+		c.CABundle = *s.CABundle
+		// ~/ и относительный путь — от домашнего и текущего каталога
+		if c.CABundle != "" {
+			if abs, err := expandHome(c.CABundle); err == nil {
+				c.CABundle = abs
+			}
+		}
+	}
+	if s.Proxy != nil {
+		c.Proxy = *s.Proxy
+	}
+	if s.UserAgent != nil {
+		c.UserAgent = *s.UserAgent
+	}
+	if s.SpeakerModel != nil {
+		c.SpeakerModel = *s.SpeakerModel
+	}
+}
+
+// UseProfile пересчитывает итоговые значения в порядке
+// defaults < file < profile < env. Флаги накладываются вызывающим кодом.
+func (c *Config) UseProfile(name string) error {
+	c.Model = DefaultModel
+	c.Wait = DefaultWait
+	c.Output = DefaultOutput
+	c.Clipboard = true
+	c.Notifications = true
+	c.Device = ""
+	c.Headless = false
+	c.CatalogTTL = DefaultCatalogTTL
+	c.AutoInstall = DefaultAutoInstall
+	c.DownloadConcurrency = DefaultDownloadConcurrency
+	c.Quota = 0
+	c.ConnectTimeout = models.DefaultConnectTimeout
+	c.ReadTimeout = models.DefaultReadTimeout
+	c.Retries = models.DefaultRetries
+	c.CABundle = ""
+	c.Proxy = ""
+	c.UserAgent = ""
+	c.SpeakerModel = DefaultSpeakerModel
+	c.Profile = name
+
+	c.apply(c.file.Settings)
+	if name != "" {
+		profile, ok := c.file.Profiles[name]
+		if !ok {
+			return fmt.Errorf("profile %q not found in %s", name, c.Path)
+		}
+		c.apply(profile)
 	}
-	_modelDir, err := getModelDir()
+	c.apply(c.env)
+	c.Checksums = c.file.Checksums
+	c.Repos = c.file.Repos
+	c.Aliases = c.file.Aliases
+	return nil
+}
+
+// Get возвращает итоговое значение ключа; ключи profiles.<name>.<key>
+// читаются напрямую из файла.
+func (c *Config) Get(key string) (string, error) {
+	if name, sub, ok := profileKey(key); ok {
+		profile, ok := c.file.Profiles[name]
+		if !ok {
+			return "", fmt.Errorf("profile %q not found", name)
+		}
+		value, _ := profile.Get(sub)
+		return value, nil
+	}
+	if name, ok := strings.CutPrefix(key, "checksums."); ok && name != "" {
+		return c.Checksums[name], nil
+	}
+	if name, ok := strings.CutPrefix(key, "aliases."); ok && name != "" {
+		return c.Aliases[name], nil
+	}
+	switch key {
+	case "model":
+		return c.Model, nil
+	case "wait":
+		return strconv.Itoa(c.Wait), nil
+	case "output":
+		return c.Output, nil
+	case "clipboard":
+		return strconv.FormatBool(c.Clipboard), nil
+	case "notifications":
+		return strconv.FormatBool(c.Notifications), nil
+	case "device":
+		return c.Device, nil
+	case "headless":
+		return strconv.FormatBool(c.Headless), nil
+	case "catalog_ttl":
+		return c.CatalogTTL.String(), nil
+	case "auto_install":
+		return c.AutoInstall, nil
+	case "download_concurrency":
+		return strconv.Itoa(c.DownloadConcurrency), nil
+	case "quota":
+		return models.FormatSize(c.Quota), nil
+	case "connect_timeout":
+		return c.ConnectTimeout.String(), nil
+	case "read_timeout":
+		return c.ReadTimeout.String(), nil
+	case "retries":
+		return strconv.Itoa(c.Retries), nil
+	case "ca_bundle":
+		return c.CABundle, nil
+	case "proxy":
+		return c.Proxy, nil
+	case "user_agent":
+		return c.UserAgent, nil
+	case "speaker_model":
+		return c.SpeakerModel, nil
+	case "profile":
+		return c.Profile, nil
+	}
+	return "", fmt.Errorf("unknown config key %q", key)
+}
+
+// Set записывает значение ключа в файл конфигурации.
+func (c *Config) Set(key, value string) error {
+	if name, sub, ok := profileKey(key); ok {
+		if c.file.Profiles == nil {
+			c.file.Profiles = make(map[string]Settings)
+		}
+		profile := c.file.Profiles[name]
+		if err := profile.Set(sub, value); err != nil {
+			return err
+		}
+		c.file.Profiles[name] = profile
+	} else if key == "profile" {
+		if _, ok := c.file.Profiles[value]; value != "" && !ok {
+			return fmt.Errorf("profile %q not found", value)
+		}
+		c.file.Profile = &value
+	} else if name, ok := strings.CutPrefix(key, "checksums."); ok && name != "" {
+		if err := setChecksum(c.file, name, value); err != nil {
+			return err
+		}
+	} else if name, ok := strings.CutPrefix(key, "aliases."); ok && name != "" {
+		if err := setAlias(c.file, name, value); err != nil {
+			return err
+		}
+	} else if err := c.file.Set(key, value); err != nil {
+		return err
+	}
+	if err := writeFile(c.Path, c.file); err != nil {
+		return err
+	}
+	return c.UseProfile(c.Profile)
+}
+
+// Show возвращает итоговые значения всех ключей в виде key = value.
+func (c *Config) Show() []string {
+	var lines []string
+	for _, key := range append(slices.Clone(Keys), "profile") {
+		value, _ := c.Get(key)
+		lines = append(lines, fmt.Sprintf("%s = %s", key, value))
+	}
+	for _, name := range sortedKeys(c.file.Profiles) {
+		profile := c.file.Profiles[name]
+		for _, key := range Keys {
+			if value, ok := profile.Get(key); ok {
+				lines = append(lines, fmt.Sprintf("profiles.%s.%s = %s", name, key, value))
+			}
+		}
+	}
+	for _, name := range sortedKeys(c.Checksums) {
+		lines = append(lines, fmt.Sprintf("checksums.%s = %s", name, c.Checksums[name]))
+	}
+	for _, name := range sortedKeys(c.Aliases) {
+		lines = append(lines, fmt.Sprintf("aliases.%s = %s", name, c.Aliases[name]))
+	}
+	// заголовки не показываем: в них обычно токены
+	for _, repo := range c.Repos {
+		lines = append(lines, fmt.Sprintf("repos.%s = %s", repo.Name, repo.URL))
+	}
+	return lines
+}
+
+// AddRepo добавляет репозиторий в конец списка и сохраняет файл.
+func (c *Config) AddRepo(repo Repo) error {
+	if slices.ContainsFunc(c.file.Repos, func(r Repo) bool { return r.Name == repo.Name }) {
+		return fmt.Errorf("repo %q already exists", repo.Name)
+	}
+	c.file.Repos = append(c.file.Repos, repo)
+	if err := writeFile(c.Path, c.file); err != nil {
+		return err
+	}
+	return c.UseProfile(c.Profile)
+}
+
+// RemoveRepo удаляет репозиторий по имени и сохраняет файл.
+func (c *Config) RemoveRepo(name string) error {
+	i := slices.IndexFunc(c.file.Repos, func(r Repo) bool { return r.Name == name })
+	if i < 0 {
+		return fmt.Errorf("repo %q not found", name)
+	}
+	c.file.Repos = slices.Delete(c.file.Repos, i, i+1)
+	if err := writeFile(c.Path, c.file); err != nil {
+		return err
+	}
+	return c.UseProfile(c.Profile)
+}
+
+// setChecksum закрепляет SHA-256 архива модели; пустое значение снимает его.
+func setChecksum(file *File, name, value string) error {
+	if value == "" {
+		delete(file.Checksums, name)
+		return nil
+	}
+	value = strings.ToLower(value)
+	if len(value) != 64 || strings.Trim(value, "0123456789abcdef") != "" {
+		return fmt.Errorf("checksum must be a hex encoded SHA-256")
+	}
+	if file.Checksums == nil {
+		file.Checksums = make(map[string]string)
+	}
+	file.Checksums[name] = value
+	return nil
+}
+
+// setAlias задаёт короткое имя модели; пустое значение удаляет его.
+func setAlias(file *File, name, value string) error {
+	if err := validAlias(name); err != nil {
+		return err
+	}
+	if value == "" {
+		delete(file.Aliases, name)
+		return nil
+	}
+	if _, ok := file.Aliases[value]; ok {
+		return fmt.Errorf("alias %q points to another alias %q, use a model name", name, value)
+	}
+	if file.Aliases == nil {
+		file.Aliases = make(map[string]string)
+	}
+	file.Aliases[name] = value
+	return nil
+}
+
+// validAlias не пускает в алиасы пути: иначе алиас перекрыл бы модель.
+func validAlias(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t/.") {
+		return fmt.Errorf("invalid alias %q: use letters, digits, - and _", name)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func profileKey(key string) (string, string, bool) {
+	parts := strings.Split(key, ".")
+	if len(parts) != 3 || parts[0] != "profiles" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// Session определяет тип графической сессии. Вызывается только теми,
+// кому нужен дисплей (буфер обмена, уведомления), поэтому без сессии
+// sluhach продолжает работать в cron, контейнерах и по SSH.
+func (c *Config) Session() (string, error) {
+	if c.Headless {
+		return "", ErrNoDisplay
+	}
+	if session := os.Getenv("XDG_SESSION_TYPE"); session != "" && session != "tty" {
+		return session, nil
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		return "wayland", nil
+	}
+	if os.Getenv("DISPLAY") != "" {
+		return "x11", nil
+	}
+	return "", ErrNoDisplay
+}
+
+// New собирает конфигурацию. Непустые configPath и modelDir приходят из
+// флагов командной строки и имеют наивысший приоритет.
+func New(
+	configPath string,
+	modelDir string,
+) (*Config, error) {
+	_modelDir, err := getModelDir(modelDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get dir with models: %w", err)
 	}
 	if err := fs.CreateDirs(_modelDir); err != nil {
 		return nil, fmt.Errorf("failed to create model dirs: %w", err)
 	}
-	return &Config{
-		SessionType: _sessionType,
-		ModelDir:    _modelDir,
-	}, nil
+	_modelPaths, err := getModelPaths(_modelDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get model search paths: %w", err)
+	}
+	_cacheDir, err := getCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	_path, err := getConfigPath(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config path: %w", err)
+	}
+	_file, err := readFile(_path)
+	if err != nil {
+		return nil, err
+	}
+	_env, _profile, err := readEnv()
+	if err != nil {
+		return nil, err
+	}
+	if _profile == "" && _file.Profile != nil {
+		_profile = *_file.Profile
+	}
+	_config := &Config{
+		ModelDir:   _modelDir,
+		ModelPaths: _modelPaths,
+		CacheDir:   _cacheDir,
+		Path:       _path,
+		file:       _file,
+		env:        _env,
+	}
+	if err := _config.UseProfile(_profile); err != nil {
+		return nil, err
+	}
+	return _config, nil
 }
@@ -2,37 +2,69 @@ package sluhach
 
 import (
 	"context"
-	"fmt"
+	"errors"
 
 	"sluhach/internal/command"
 	"sluhach/internal/config"
 
+	"sluhach/pkg/clip"
 	"sluhach/pkg/models"
+	"sluhach/pkg/notify"
 	"sluhach/pkg/stt"
 )
 
+// Коды возврата; описаны в справке корневой команды и в README.
+const (
+	ExitOK = iota
+	ExitError
+	ExitUsage
+	ExitModelNotFound
+	ExitDeviceUnavailable
+	ExitNoSpeech
+	ExitNetwork
+	ExitChecksumMismatch
+	ExitDisplayUnavailable
+	ExitNoSpace
+)
+
 type Sluhach struct {
 	cmd *command.Command
 }
 
-func New(
-	modelpath string,
-) (*Sluhach, error) {
-	_config, err := config.New()
-	if err != nil {
-		return nil, fmt.Errorf("config error: %w", err)
-	}
-	_stt := stt.New(_config.ModelDir)
-	_manager := models.New(_config.ModelDir)
-	_cmd := command.New(
-		_stt,
-		_manager,
-	)
+func New() *Sluhach {
 	return &Sluhach{
-		cmd: _cmd,
-	}, nil
+		cmd: command.New(),
+	}
 }
 
 func (s *Sluhach) Start() error {
 	return s.cmd.Execute(context.Background())
 }
+
+// ExitCode сопоставляет ошибку из Start коду возврата процесса.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, command.ErrUsage), errors.Is(err, command.ErrConfig),
+		errors.Is(err, stt.ErrUnsupportedAudio), errors.Is(err, stt.ErrNoChannel):
+		return ExitUsage
+	case errors.Is(err, stt.ErrModelNotFound), errors.Is(err, models.ErrModelNotFound):
+		return ExitModelNotFound
+	case errors.Is(err, stt.ErrDeviceUnavailable):
+		return ExitDeviceUnavailable
+	case errors.Is(err, stt.ErrNoSpeech):
+		return ExitNoSpeech
+	case errors.Is(err, models.ErrNetwork):
+		return ExitNetwork
+	case errors.Is(err, models.ErrChecksumMismatch):
+		return ExitChecksumMismatch
+	case errors.Is(err, clip.ErrUnavailable),
+		errors.Is(err, notify.ErrUnavailable),
+		errors.Is(err, config.ErrNoDisplay):
+		return ExitDisplayUnavailable
+	case errors.Is(err, models.ErrNoSpace):
+		return ExitNoSpace
+	}
+	return ExitError
+}
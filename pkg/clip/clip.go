@@ -1,14 +1,20 @@
 package clip
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/atotto/clipboard"
 )
 
+var ErrUnavailable = errors.New("clipboard unavailable")
+
 func Clip(s string) error {
+	if clipboard.Unsupported {
+		return ErrUnavailable
+	}
 	if err := clipboard.WriteAll(s); err != nil {
-		return fmt.Errorf("failed to copy to clipboard: %w", err)
+		return fmt.Errorf("%w: failed to copy to clipboard: %w", ErrUnavailable, err)
 	}
 	return nil
 }
@@ -0,0 +1,279 @@
+package fs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// сигнатуры форматов: архив распознаётся по содержимому, а не по расширению
+var (
+	magicZip  = []byte("PK\x03\x04")
+	magicGzip = []byte{0x1f, 0x8b}
+	magicXz   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	magicZstd = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	// у tar сигнатура не в начале, а по смещению 257
+	magicTar = []byte("ustar")
+)
+
+const tarMagicOffset = 257
+
+// maxLinkTarget — предел длины цели символической ссылки в zip.
+const maxLinkTarget = 4096
+
+var ErrUnsupportedArchive = errors.New("unsupported archive format")
+
+// Extract распаковывает архив src в dest, определяя формат по содержимому:
+// zip, tar, tar.gz, tar.xz или tar.zst.
+func Extract(src, dest string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	// короткий файл вернёт ошибку, но то, что прочитано, нам и нужно
+	head, _ := br.Peek(tarMagicOffset + len(magicTar))
+
+	switch {
+	case bytes.HasPrefix(head, magicZip):
+		return Unzip(src, dest)
+	case bytes.HasPrefix(head, magicGzip):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip: %w", err)
+		}
+		defer gz.Close()
+		return untar(gz, dest)
+	case bytes.HasPrefix(head, magicXz):
+		r, err := xz.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("failed to open xz: %w", err)
+		}
+		return untar(r, dest)
+	case bytes.HasPrefix(head, magicZstd):
+		d, err := zstd.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("failed to open zstd: %w", err)
+		}
+		defer d.Close()
+		return untar(d, dest)
+	case len(head) == tarMagicOffset+len(magicTar) && bytes.Equal(head[tarMagicOffset:], magicTar):
+		return untar(br, dest)
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedArchive, src)
+}
+
+// Unzip распаковывает zip‑архив src в dest.
+func Unzip(src, dest string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("failed to open zip: %w", err)
+	}
+	defer r.Close()
+
+	e, err := newExtractor(dest)
+	if err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		switch mode := f.Mode(); {
+		case mode.IsDir():
+			err = e.dir(f.Name)
+		case mode&os.ModeSymlink != 0:
+			err = e.zipLink(f)
+		case mode.IsRegular():
+			err = e.zipFile(f)
+		default:
+			err = fmt.Errorf("unsupported entry %s in zip", f.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.finish()
+}
+
+func (e *extractor) zipFile(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open file in zip %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	return e.file(f.Name, f.Mode(), rc)
+}
+
+func (e *extractor) zipLink(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open file in zip %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	// в zip цель ссылки хранится как содержимое записи
+	target, err := io.ReadAll(io.LimitReader(rc, maxLinkTarget))
+	if err != nil {
+		return fmt.Errorf("failed to read link %s: %w", f.Name, err)
+	}
+	return e.symlink(f.Name, string(target))
+}
+
+// untar распаковывает несжатый поток tar.
+func untar(r io.Reader, dest string) error {
+	e, err := newExtractor(dest)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar: %w", err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = e.dir(hdr.Name)
+		case tar.TypeReg:
+			err = e.file(hdr.Name, hdr.FileInfo().Mode(), tr)
+		case tar.TypeSymlink:
+			err = e.symlink(hdr.Name, hdr.Linkname)
+		case tar.TypeXGlobalHeader:
+			// служебный pax‑заголовок без содержимого
+		default:
+			// жёсткие ссылки, устройства, FIFO моделям не нужны
+			err = fmt.Errorf("unsupported entry %s in tar", hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.finish()
+}
+
+// extractor пишет записи архива в dest. Символические ссылки создаются
+// в самом конце: так ни один файл не будет записан через ссылку, а цель
+// каждой ссылки проверяется по реальному дереву, а не по тексту пути.
+type extractor struct {
+	dest  string
+	links []link
+}
+
+type link struct {
+	path   string
+	target string
+}
+
+func newExtractor(dest string) (*extractor, error) {
+	if err := CreateDirs(dest); err != nil {
+		return nil, fmt.Errorf("failed to create dir %s: %w", dest, err)
+	}
+	// dest и сам может лежать за ссылкой, сравнивать будем реальные пути
+	real, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dest, err)
+	}
+	return &extractor{dest: real}, nil
+}
+
+// inside сообщает, что путь лежит внутри dest или совпадает с ним.
+func (e *extractor) inside(path string) bool {
+	return path == e.dest || strings.HasPrefix(path, e.dest+string(os.PathSeparator))
+}
+
+// path переводит имя записи в путь внутри dest; защита от Zip Slip.
+func (e *extractor) path(name string) (string, error) {
+	fpath := filepath.Join(e.dest, name)
+	if !e.inside(fpath) {
+		return "", fmt.Errorf("illegal file path: %s", name)
+	}
+	return fpath, nil
+}
+
+func (e *extractor) dir(name string) error {
+	fpath, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	if err := CreateDirs(fpath); err != nil {
+		return fmt.Errorf("failed to create dir %s: %w", fpath, err)
+	}
+	return nil
+}
+
+func (e *extractor) file(name string, mode os.FileMode, r io.Reader) error {
+	fpath, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	if fpath == e.dest {
+		return fmt.Errorf("illegal file path: %s", name)
+	}
+	if err := CreateDirs(filepath.Dir(fpath)); err != nil {
+		return fmt.Errorf("failed to create dir %s: %w", filepath.Dir(fpath), err)
+	}
+
+	dstFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", fpath, err)
+	}
+	if _, err := io.Copy(dstFile, r); err != nil {
+		dstFile.Close()
+		return fmt.Errorf("failed to copy file %s: %w", fpath, err)
+	}
+	if err := dstFile.Close(); err != nil {
+		return fmt.Errorf("failed to close file %s: %w", fpath, err)
+	}
+	return nil
+}
+
+// symlink откладывает создание ссылки до finish. Абсолютные цели
+// запрещены сразу: внутри dest они никогда не указывают.
+func (e *extractor) symlink(name, target string) error {
+	fpath, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	if fpath == e.dest || target == "" || filepath.IsAbs(target) {
+		return fmt.Errorf("illegal symlink: %s -> %s", name, target)
+	}
+	e.links = append(e.links, link{path: fpath, target: target})
+	return nil
+}
+
+// finish создаёт отложенные ссылки. Цель разрешается по уже созданному
+// дереву со всеми ссылками, поэтому цепочки вида a -> b/../.. не уводят
+// за пределы dest. Висячие ссылки не допускаются.
+func (e *extractor) finish() error {
+	for _, l := range e.links {
+		if err := CreateDirs(filepath.Dir(l.path)); err != nil {
+			return fmt.Errorf("failed to create dir %s: %w", filepath.Dir(l.path), err)
+		}
+		// не filepath.Join: он схлопнул бы ".." по тексту, до разрешения ссылок
+		resolved, err := filepath.EvalSymlinks(filepath.Dir(l.path) + string(os.PathSeparator) + l.target)
+		if err != nil {
+			return fmt.Errorf("illegal symlink: %s -> %s: %w", l.path, l.target, err)
+		}
+		if !e.inside(resolved) {
+			return fmt.Errorf("illegal symlink: %s -> %s points outside", l.path, l.target)
+		}
+		if err := os.Symlink(l.target, l.path); err != nil {
+			return fmt.Errorf("failed to create symlink %s: %w", l.path, err)
+		}
+	}
+	return nil
+}
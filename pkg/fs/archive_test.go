@@ -0,0 +1,201 @@
+package fs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// entry — запись тестового архива.
+type entry struct {
+	name string
+	// kind: file, dir, symlink, hardlink или device
+	kind string
+	// body — содержимое файла или цель ссылки
+	body string
+}
+
+// formats собирают архив из записей; zip не умеет жёстких ссылок, вместо
+// них пишется устройство.
+var formats = []struct {
+	name  string
+	write func(t *testing.T, path string, entries []entry)
+}{
+	{"zip", writeZip},
+	{"tar.gz", writeTar(func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil })},
+	{"tar.xz", writeTar(func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) })},
+	{"tar.zst", writeTar(func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) })},
+}
+
+func writeZip(t *testing.T, path string, entries []entry) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		switch e.kind {
+		case "file":
+			hdr.SetMode(0o644)
+		case "dir":
+			hdr.Name += "/"
+			hdr.SetMode(os.ModeDir | 0o755)
+		case "symlink":
+			hdr.SetMode(os.ModeSymlink | 0o777)
+		case "hardlink", "device":
+			hdr.SetMode(os.ModeDevice | 0o644)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, e.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTar(compress func(io.Writer) (io.WriteCloser, error)) func(*testing.T, string, []entry) {
+	return func(t *testing.T, path string, entries []entry) {
+		t.Helper()
+		var buf bytes.Buffer
+		cw, err := compress(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tw := tar.NewWriter(cw)
+		for _, e := range entries {
+			hdr := &tar.Header{Name: e.name, Mode: 0o644}
+			switch e.kind {
+			case "file":
+				hdr.Typeflag, hdr.Size = tar.TypeReg, int64(len(e.body))
+			case "dir":
+				hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+			case "symlink":
+				hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, e.body
+			case "hardlink":
+				hdr.Typeflag, hdr.Linkname = tar.TypeLink, e.body
+			case "device":
+				hdr.Typeflag, hdr.Devmajor, hdr.Devminor = tar.TypeChar, 1, 3
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+			if e.kind == "file" {
+				if _, err := io.WriteString(tw, e.body); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := cw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExtractRejectsMaliciousArchives(t *testing.T) {
+	cases := []struct {
+		name    string
+		entries []entry
+		// want — часть текста ошибки, по которой видно, что сработала
+		// нужная проверка
+		want string
+	}{
+		{"zip slip", []entry{
+			{name: "conf/model.conf", kind: "file", body: "ok"},
+			{name: "../outside/evil", kind: "file", body: "pwned"},
+		}, "illegal file path"},
+		{"absolute symlink", []entry{
+			{name: "graph", kind: "symlink", body: "/etc"},
+		}, "illegal symlink"},
+		{"symlink chain", []entry{
+			{name: "a", kind: "symlink", body: "."},
+			{name: "a/b", kind: "symlink", body: ".."},
+		}, "points outside"},
+		{"dangling symlink", []entry{
+			{name: "graph", kind: "symlink", body: "missing"},
+		}, "no such file"},
+		{"hardlink", []entry{
+			{name: "conf/model.conf", kind: "file", body: "ok"},
+			{name: "am", kind: "hardlink", body: "conf/model.conf"},
+		}, "unsupported entry am"},
+		{"device", []entry{
+			{name: "null", kind: "device"},
+		}, "unsupported entry null"},
+		{"write through symlink", []entry{
+			{name: "graph", kind: "symlink", body: "../outside"},
+			{name: "graph/evil", kind: "file", body: "pwned"},
+		}, "points outside"},
+	}
+
+	for _, format := range formats {
+		for _, tc := range cases {
+			t.Run(format.name+"/"+tc.name, func(t *testing.T) {
+				root := t.TempDir()
+				dest, outside := filepath.Join(root, "dest"), filepath.Join(root, "outside")
+				if err := os.Mkdir(outside, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				archive := filepath.Join(root, "model."+format.name)
+				format.write(t, archive, tc.entries)
+
+				err := Extract(archive, dest)
+				if err == nil || !strings.Contains(err.Error(), tc.want) {
+					t.Fatalf("got %v, want error with %q", err, tc.want)
+				}
+				if entries, _ := os.ReadDir(outside); len(entries) > 0 {
+					t.Fatalf("archive wrote outside dest: %s", entries[0].Name())
+				}
+				if _, err := os.Lstat(filepath.Join(root, "b")); err == nil {
+					t.Fatal("symlink chain created a link outside dest")
+				}
+			})
+		}
+	}
+}
+
+func TestExtractKeepsSafeSymlinks(t *testing.T) {
+	entries := []entry{
+		{name: "am/final.mdl", kind: "file", body: "model"},
+		{name: "graph", kind: "dir"},
+		{name: "graph/HCLr.fst", kind: "file", body: "fst"},
+		{name: "current", kind: "symlink", body: "graph"},
+		{name: "am/graph", kind: "symlink", body: "../graph/HCLr.fst"},
+	}
+	for _, format := range formats {
+		t.Run(format.name, func(t *testing.T) {
+			root := t.TempDir()
+			dest := filepath.Join(root, "dest")
+			archive := filepath.Join(root, "model."+format.name)
+			format.write(t, archive, entries)
+
+			if err := Extract(archive, dest); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(filepath.Join(dest, "am", "graph"))
+			if err != nil || string(data) != "fst" {
+				t.Fatalf("link am/graph: %q, %v", data, err)
+			}
+		})
+	}
+}
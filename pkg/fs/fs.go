@@ -1,12 +1,12 @@
 package fs
 
 import (
-	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 func Exists(path string) error {
@@ -34,54 +34,50 @@ func Remove(path string) error {
 	return os.RemoveAll(path)
 }
 
-func Unzip(src, dest string) error {
-	r, err := zip.OpenReader(src)
+// WriteFileAtomic пишет файл через временный в том же каталоге и
+// переименование, так что читатель видит либо старое содержимое, либо новое,
+// но не наполовину записанное. Недостающие каталоги создаются.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := CreateDirs(dir); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+"-*")
 	if err != nil {
-		return fmt.Errorf("failed to open zip: %w", err)
+		return err
 	}
-	defer r.Close()
-
-	for _, f := range r.File {
-		fpath := filepath.Join(dest, f.Name)
-
-		// защита от Zip Slip
-		if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
-			return fmt.Errorf("illegal file path: %s", fpath)
-		}
-
-		if f.FileInfo().IsDir() {
-			if err := CreateDirs(fpath); err != nil {
-				return fmt.Errorf("failed to create dir %s: %w", fpath, err)
-			}
-			continue
-		}
-
-		if err := CreateDirs(filepath.Dir(fpath)); err != nil {
-			return fmt.Errorf("failed to create dir %s: %w", filepath.Dir(fpath), err)
-		}
-
-		dstFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			return fmt.Errorf("failed to create file %s: %w", fpath, err)
-		}
-
-		rc, err := f.Open()
-		if err != nil {
-			dstFile.Close()
-			return fmt.Errorf("failed to open file in zip %s: %w", f.Name, err)
-		}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
 
-		if _, err := io.Copy(dstFile, rc); err != nil {
-			rc.Close()
-			dstFile.Close()
-			return fmt.Errorf("failed to copy file %s: %w", fpath, err)
-		}
+// CopyDir копирует дерево src в несуществующий каталог dst.
+// Символические ссылки не поддерживаются.
+func CopyDir(src, dst string) error {
+	return os.CopyFS(dst, os.DirFS(src))
+}
 
-		rc.Close()
-		if err := dstFile.Close(); err != nil {
-			return fmt.Errorf("failed to close file %s: %w", fpath, err)
-		}
+// SHA256 возвращает hex‑строку SHA-256 содержимого файла.
+func SHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
 	}
+	defer f.Close()
 
-	return nil
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
@@ -0,0 +1,35 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "models", ".stats.json")
+
+	// каталог создаётся, старое содержимое заменяется целиком
+	for _, data := range []string{"first version", "second"} {
+		if err := WriteFileAtomic(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != data {
+			t.Fatalf("got %q, %v, want %q", got, err, data)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Fatalf("mode %v, want 0644", info.Mode().Perm())
+	}
+	// временных файлов не остаётся
+	if names, _ := List(filepath.Dir(path)); len(names) != 1 {
+		t.Fatalf("dir has %q, want only the written file", names)
+	}
+}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+)
+
+// chunkSize — размер куска при параллельной загрузке. Кусков больше, чем
+// потоков: при обрыве теряется только то, что качалось в этот момент.
+const chunkSize = 16 << 20
+
+// errArchiveChanged — архив на сервере сменился посреди загрузки кусками.
+var errArchiveChanged = errors.New("archive changed on the server")
+
+// WithConcurrency задаёт число одновременных запросов Range при загрузке
+// архива. 1 и меньше — загрузка одним потоком.
+func WithConcurrency(n int) Option {
+	return func(m *Manager) {
+		if n > 1 {
+			m.concurrency = n
+		}
+	}
+}
+
+// parallel сообщает, стоит ли качать архив кусками: сервер принимает Range,
+// архив больше одного куска и есть валидатор для If-Range — без него куски
+// разных версий архива могли бы смешаться.
+func (m *Manager) parallel(resp *http.Response, meta *partial) bool {
+	return m.concurrency > 1 &&
+		resp.Header.Get("Accept-Ranges") == "bytes" &&
+		meta.Size > chunkSize &&
+		meta.validator() != ""
+}
+
+// chunkLoader качает архив кусками в несколько потоков. Каждый кусок
+// пишется в файл по своему смещению, так что порядок сборки не зависит от
+// порядка ответов. Готовые куски отмечаются в partial, и прерванная
+// загрузка продолжается только с недокачанных.
+type chunkLoader struct {
+	m    *Manager
+	url  string
+	dst  string
+	f    *os.File
+	meta *partial
+
+	// mu защищает meta.Done и счётчик: Progress вызывается из одного
+	// потока за раз и видит общий объём по всем кускам.
+	mu         sync.Mutex
+	downloaded int64
+}
+
+// downloadChunks качает url в dst кусками; meta без Done — новая загрузка.
+func (m *Manager) downloadChunks(model, url, dst string, meta *partial) error {
+	fresh := meta.Done == nil
+	flag := os.O_WRONLY
+	if fresh {
+		meta.ChunkSize = chunkSize
+		meta.Done = make([]bool, (meta.Size+chunkSize-1)/chunkSize)
+		flag |= os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(dst, flag, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open model archive: %w", err)
+	}
+	defer f.Close()
+	if fresh {
+		// файл сразу полного размера: куски пишутся каждый на своё место
+		if err := f.Truncate(meta.Size); err != nil {
+			return fmt.Errorf("failed to allocate model archive: %w", err)
+		}
+	}
+	if err := meta.save(dst); err != nil {
+		return fmt.Errorf("failed to save download state: %w", err)
+	}
+
+	l := &chunkLoader{m: m, url: url, dst: dst, f: f, meta: meta}
+	var pending []int
+	for i, done := range meta.Done {
+		if done {
+			l.downloaded += l.size(i)
+		} else {
+			pending = append(pending, i)
+		}
+	}
+
+	m.progress.Start(path.Base(url), l.downloaded, meta.Size)
+	err = l.run(pending)
+	m.progress.Finish(err)
+	if errors.Is(err, errArchiveChanged) {
+		// скачанные куски относятся к старой версии, download начнёт заново
+		f.Close()
+		removePartial(dst)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close model archive: %w", err)
+	}
+	if l.downloaded != meta.Size {
+		return fmt.Errorf("failed to download model: %w: got %d of %d bytes", ErrNetwork, l.downloaded, meta.Size)
+	}
+	return nil
+}
+
+// run раздаёт куски потокам; первая ошибка отменяет остальные запросы.
+func (l *chunkLoader) run(pending []int) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+		next  = make(chan int)
+	)
+	for range min(l.m.concurrency, len(pending)) {
+		wg.Go(func() {
+			for i := range next {
+				if err := l.fetch(ctx, i); err != nil {
+					once.Do(func() {
+						first = err
+						cancel()
+					})
+					return
+				}
+			}
+		})
+	}
+feed:
+	for _, i := range pending {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	return first
+}
+
+// size — длина куска i; последний обычно короче.
+func (l *chunkLoader) size(i int) int64 {
+	start := int64(i) * l.meta.ChunkSize
+	return min(l.meta.ChunkSize, l.meta.Size-start)
+}
+
+// fetch качает кусок i и отмечает его готовым.
+func (l *chunkLoader) fetch(ctx context.Context, i int) error {
+	start, size := int64(i)*l.meta.ChunkSize, l.size(i)
+
+	req, err := l.m.newRequest(http.MethodGet, l.url)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, start+size-1))
+	req.Header.Set("If-Range", l.meta.validator())
+
+	resp, err := l.m.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download chunk %d: %w: %w", i, ErrNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		_start, end, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return fmt.Errorf("failed to download chunk %d: %w: %w", i, ErrNetwork, err)
+		}
+		// размер "*" допустим: If-Range уже подтвердил, что версия та же
+		if total >= 0 && total != l.meta.Size {
+			return errArchiveChanged
+		}
+		if _start != start || end != start+size-1 {
+			return fmt.Errorf("failed to download chunk %d: %w: server sent range %d-%d instead of %d-%d", i, ErrNetwork, _start, end, start, start+size-1)
+		}
+	case http.StatusOK:
+		// If-Range не совпал: сервер отдаёт новую версию целиком
+		return errArchiveChanged
+	default:
+		return fmt.Errorf("failed to download chunk %d: %w: status %s", i, ErrNetwork, resp.Status)
+	}
+
+	var (
+		w       = io.NewOffsetWriter(l.f, start)
+		body    = io.LimitReader(resp.Body, size)
+		buf     = make([]byte, 32*1024)
+		written int64
+	)
+	for {
+		n, _err := body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return fmt.Errorf("failed to save model archive: %w", err)
+			}
+			written += int64(n)
+			l.add(int64(n))
+		}
+		if errors.Is(_err, io.EOF) {
+			break
+		}
+		if _err != nil {
+			return fmt.Errorf("failed to download chunk %d (run again to resume): %w: %w: %w", i, ErrNetwork, errInterrupted, _err)
+		}
+	}
+	if written != size {
+		return fmt.Errorf("failed to download chunk %d: %w: got %d of %d bytes", i, ErrNetwork, written, size)
+	}
+	return l.done(i)
+}
+
+func (l *chunkLoader) add(n int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.downloaded += n
+	l.m.progress.Update(l.downloaded)
+}
+
+// done отмечает кусок готовым и сразу сохраняет состояние на диск.
+func (l *chunkLoader) done(i int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.meta.Done[i] = true
+	if err := l.meta.save(l.dst); err != nil {
+		return fmt.Errorf("failed to save download state: %w", err)
+	}
+	return nil
+}
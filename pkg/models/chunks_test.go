@@ -0,0 +1,180 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// chunkedArchive — архив на три куска, последний неполный.
+func chunkedArchive() []byte {
+	return testArchive(2*chunkSize + 100<<10)
+}
+
+// chunkRanges — запросы кусков chunkedArchive с If-Range etag.
+func chunkRanges(etag string) []string {
+	size := int64(2*chunkSize + 100<<10)
+	var ranges []string
+	for start := int64(0); start < size; start += chunkSize {
+		ranges = append(ranges, fmt.Sprintf("bytes=%d-%d|%s", start, min(start+chunkSize, size)-1, etag))
+	}
+	return ranges
+}
+
+func TestDownloadChunksReassembles(t *testing.T) {
+	data := chunkedArchive()
+	var log requestLog
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.add(r)
+		serveArchive(w, r, `"v1"`, data)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, WithConcurrency(3))
+	dst := m.partialPath("model.zip")
+	if err := m.download("model", srv.URL+"/model.zip", dst); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, dst, data)
+
+	got := log.all()
+	// первый запрос узнаёт размер и ETag, дальше по запросу на кусок
+	want := append([]string{"|"}, chunkRanges(`"v1"`)...)
+	slices.Sort(got[1:])
+	if !slices.Equal(got, want) {
+		t.Fatalf("requests %q, want %q", got, want)
+	}
+	if meta := readPartial(dst); meta == nil || !slices.Equal(meta.Done, []bool{true, true, true}) {
+		t.Fatalf("chunks not marked done: %+v", meta)
+	}
+}
+
+func TestDownloadChunksResumesFromDone(t *testing.T) {
+	data := chunkedArchive()
+	var log requestLog
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.add(r)
+		serveArchive(w, r, `"v1"`, data)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, WithConcurrency(3))
+	dst := m.partialPath("model.zip")
+	url := srv.URL + "/model.zip"
+	// средний кусок не докачан: на его месте нули
+	local := slices.Clone(data)
+	clear(local[chunkSize : 2*chunkSize])
+	writePartial(t, dst, local, partial{
+		URL: url, ETag: `"v1"`, Size: int64(len(data)),
+		ChunkSize: chunkSize, Done: []bool{true, false, true},
+	})
+
+	if err := m.download("model", url, dst); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, dst, data)
+	if got, want := log.all(), chunkRanges(`"v1"`)[1:2]; !slices.Equal(got, want) {
+		t.Fatalf("requests %q, want only %q", got, want)
+	}
+}
+
+func TestDownloadChunksRestartsWhenETagChanged(t *testing.T) {
+	old, data := chunkedArchive(), chunkedArchive()
+	slices.Reverse(data)
+	var log requestLog
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// архив обновили сразу после первого запроса: куски с If-Range
+		// старой версии получают новую целиком
+		if log.add(r) == 1 {
+			serveArchive(w, r, `"v1"`, old)
+			return
+		}
+		serveArchive(w, r, `"v2"`, data)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, WithConcurrency(3))
+	dst := m.partialPath("model.zip")
+	if err := m.download("model", srv.URL+"/model.zip", dst); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, dst, data)
+	if meta := readPartial(dst); meta == nil || meta.ETag != `"v2"` {
+		t.Fatalf("download state not updated: %+v", meta)
+	}
+}
+
+func TestDownloadChunksRestartsOnce(t *testing.T) {
+	data := chunkedArchive()
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ETag меняется на каждый запрос, ни один кусок не совпадёт
+		serveArchive(w, r, fmt.Sprintf(`"v%d"`, n.Add(1)), data)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, WithConcurrency(3))
+	err := m.download("model", srv.URL+"/model.zip", m.partialPath("model.zip"))
+	if !errors.Is(err, errArchiveChanged) || !errors.Is(err, ErrNetwork) {
+		t.Fatalf("got %v, want archive changed network error", err)
+	}
+	// две попытки: по запросу размера и не больше трёх кусков каждая
+	if got := n.Load(); got > 8 {
+		t.Fatalf("%d requests, want at most one restart", got)
+	}
+}
+
+func TestDownloadChunksAcceptsUnknownTotal(t *testing.T) {
+	data := chunkedArchive()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+			serveArchive(w, r, `"v1"`, data)
+			return
+		}
+		// размер архива в Content-Range не обязателен (RFC 9110)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/*", start, end))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data[start : end+1])
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, WithConcurrency(3))
+	dst := m.partialPath("model.zip")
+	if err := m.download("model", srv.URL+"/model.zip", dst); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, dst, data)
+}
+
+func TestParseContentRange(t *testing.T) {
+	cases := []struct {
+		in                string
+		start, end, total int64
+		err               string
+	}{
+		{in: "bytes 100-999/1000", start: 100, end: 999, total: 1000},
+		{in: "bytes 0-0/*", start: 0, end: 0, total: -1},
+		{in: "bytes */1000", err: "invalid"},
+		{in: "bytes 10-5/100", err: "invalid"},
+		{in: "items 0-1/2", err: "unsupported"},
+	}
+	for _, tc := range cases {
+		start, end, total, err := parseContentRange(tc.in)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%q: got %v, want %s error", tc.in, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || start != tc.start || end != tc.end || total != tc.total {
+			t.Errorf("%q: got %d-%d/%d, %v", tc.in, start, end, total, err)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package models
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Значения ClientOptions по умолчанию.
+const (
+	DefaultConnectTimeout = 30 * time.Second
+	DefaultReadTimeout    = time.Minute
+	DefaultRetries        = 3
+)
+
+// ProxyNone в ClientOptions.Proxy отключает прокси, в том числе из окружения.
+const ProxyNone = "none"
+
+// пауза перед повтором: 1s, 2s, 4s… но не больше maxBackoff
+const (
+	baseBackoff = time.Second
+	maxBackoff  = 30 * time.Second
+)
+
+// retryStatuses — ответы, после которых запрос имеет смысл повторить.
+var retryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// errInterrupted — тело ответа оборвалось; скачанное сохранено, загрузку
+// можно продолжить.
+var errInterrupted = errors.New("download interrupted")
+
+// ClientOptions — настройки HTTP‑клиента для архивов и каталога.
+type ClientOptions struct {
+	// ConnectTimeout ограничивает установку соединения и TLS‑рукопожатие.
+	ConnectTimeout time.Duration
+	// ReadTimeout — сколько ждать ответа или следующих байт тела. Общего
+	// тайм‑аута нет: большой архив может качаться часами.
+	ReadTimeout time.Duration
+	// CABundle — PEM‑файл с корневыми сертификатами в дополнение к системным,
+	// например для зеркала за корпоративным прокси.
+	CABundle string
+	// Proxy — URL прокси (http, https, socks5). Пусто — HTTP_PROXY,
+	// HTTPS_PROXY и NO_PROXY из окружения, ProxyNone — без прокси.
+	Proxy string
+}
+
+// NewClient создаёт HTTP‑клиент по настройкам; нулевые тайм‑ауты
+// заменяются значениями по умолчанию.
+func NewClient(opts ClientOptions) (*http.Client, error) {
+	if opts.ConnectTimeout <= 0 {
+		opts.ConnectTimeout = DefaultConnectTimeout
+	}
+	if opts.ReadTimeout <= 0 {
+		opts.ReadTimeout = DefaultReadTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   opts.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = opts.ConnectTimeout
+
+	switch opts.Proxy {
+	case "":
+		transport.Proxy = http.ProxyFromEnvironment
+	case ProxyNone:
+		transport.Proxy = nil
+	default:
+		proxy, err := ParseProxy(opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if opts.CABundle != "" {
+		pool, err := certPool(opts.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{
+		Transport: &stallTransport{base: transport, timeout: opts.ReadTimeout},
+	}, nil
+}
+
+// ParseProxy проверяет URL прокси.
+func ParseProxy(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", proxy, err)
+	}
+	if !slices.Contains([]string{"http", "https", "socks5", "socks5h"}, u.Scheme) || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: expected http://, https:// or socks5:// with a host", proxy)
+	}
+	return u, nil
+}
+
+// certPool добавляет сертификаты из PEM‑файла к системным.
+func certPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+// WithClient задаёт HTTP‑клиент, обычно созданный NewClient.
+func WithClient(client *http.Client) Option {
+	return func(m *Manager) {
+		if client != nil {
+			m.client = client
+		}
+	}
+}
+
+// WithRetries задаёт, сколько раз повторять запрос после сбоя сети или
+// ответов 429 и 5xx, а также продолжать оборвавшуюся загрузку. 0 — не повторять.
+func WithRetries(retries int) Option {
+	return func(m *Manager) {
+		m.retries = max(retries, 0)
+	}
+}
+
+// WithUserAgent заменяет заголовки браузера, с которыми ходит Manager,
+// на честный User-Agent, например "sluhach/1.2.0".
+func WithUserAgent(userAgent string) Option {
+	return func(m *Manager) {
+		m.userAgent = userAgent
+	}
+}
+
+// do выполняет запрос, повторяя его с нарастающей паузой после сбоя сети
+// и ответов из retryStatuses. Последний ответ возвращается как есть, его
+// статус разбирает вызывающий код. Запросы у Manager без тела, поэтому
+// повторять их безопасно.
+func (m *Manager) do(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := m.client.Do(req)
+		// запрос отменили сами (соседний кусок упал) — повторять незачем
+		if req.Context().Err() != nil || attempt >= m.retries {
+			return resp, err
+		}
+		if err == nil && !slices.Contains(retryStatuses, resp.StatusCode) {
+			return resp, nil
+		}
+
+		delay := backoff(attempt)
+		if err == nil {
+			delay = retryAfter(resp, delay)
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// backoff — пауза перед повтором номер attempt (с нуля): удваивается, со
+// случайной добавкой до половины, чтобы потоки не повторяли хором.
+func backoff(attempt int) time.Duration {
+	delay := min(baseBackoff<<attempt, maxBackoff)
+	return delay + rand.N(delay/2+1)
+}
+
+// retryAfter учитывает заголовок Retry-After в секундах, но ждёт не
+// дольше maxBackoff.
+func retryAfter(resp *http.Response, delay time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		return min(time.Duration(seconds)*time.Second, maxBackoff)
+	}
+	return delay
+}
+
+// stallTransport обрывает запрос, если сервер молчит дольше timeout: и
+// в ожидании ответа, и посреди тела.
+type stallTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *stallTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(req.Context())
+	stalled := fmt.Errorf("no data from server for %s", t.timeout)
+	timer := time.AfterFunc(t.timeout, func() { cancel(stalled) })
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		timer.Stop()
+		cancel(nil)
+		if context.Cause(ctx) == stalled {
+			return nil, stalled
+		}
+		return nil, err
+	}
+	resp.Body = &stallBody{
+		ReadCloser: resp.Body,
+		ctx:        ctx,
+		cancel:     cancel,
+		timer:      timer,
+		timeout:    t.timeout,
+		stalled:    stalled,
+	}
+	return resp, nil
+}
+
+type stallBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timer   *time.Timer
+	timeout time.Duration
+	stalled error
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && context.Cause(b.ctx) == b.stalled {
+		return n, b.stalled
+	}
+	b.timer.Reset(b.timeout)
+	return n, err
+}
+
+func (b *stallBody) Close() error {
+	b.timer.Stop()
+	b.cancel(nil)
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTLSServer поднимает TLS‑сервер и кладёт его сертификат в PEM‑файл,
+// который годится как CABundle.
+func newTLSServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, string) {
+	t.Helper()
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(bundle, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return srv, bundle
+}
+
+func newTestClient(t *testing.T, opts ClientOptions) *http.Client {
+	t.Helper()
+	client, err := NewClient(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestNewClientCABundle(t *testing.T) {
+	srv, bundle := newTLSServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+
+	resp, err := newTestClient(t, ClientOptions{CABundle: bundle}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("with CA bundle: %v", err)
+	}
+	resp.Body.Close()
+
+	// без сертификата сервера в пуле рукопожатие не проходит
+	if resp, err := newTestClient(t, ClientOptions{}).Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("without CA bundle: self-signed certificate was accepted")
+	} else if !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("without CA bundle: got %v, want a certificate error", err)
+	}
+}
+
+func TestNewClientRejectsEmptyCABundle(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(bundle, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient(ClientOptions{CABundle: bundle}); err == nil {
+		t.Fatal("bundle without certificates was accepted")
+	}
+}
+
+func TestNewClientCutsStalledBody(t *testing.T) {
+	srv, bundle := newTLSServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		// дальше сервер молчит, пока клиент не оборвёт соединение
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	client := newTestClient(t, ClientOptions{CABundle: bundle, ReadTimeout: 100 * time.Millisecond})
+
+	started := time.Now()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	_, err = io.ReadAll(resp.Body)
+	if err == nil || !strings.Contains(err.Error(), "no data from server") {
+		t.Fatalf("got %v, want a stall error", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("stall was cut after %s", elapsed)
+	}
+}
+
+func TestNewClientCutsStalledResponse(t *testing.T) {
+	srv, bundle := newTLSServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	client := newTestClient(t, ClientOptions{CABundle: bundle, ReadTimeout: 100 * time.Millisecond})
+
+	resp, err := client.Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("server that never answered did not time out")
+	}
+	if !strings.Contains(err.Error(), "no data from server") {
+		t.Fatalf("got %v, want a stall error", err)
+	}
+}
+
+func TestDoRetriesAfterRetryAfter(t *testing.T) {
+	var (
+		n     atomic.Int32
+		first time.Time
+		delay time.Duration
+	)
+	srv, bundle := newTLSServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		delay = time.Since(first)
+		io.WriteString(w, "ok")
+	})
+	m := newTestManager(t, WithClient(newTestClient(t, ClientOptions{CABundle: bundle})), WithRetries(1))
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := m.do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || n.Load() != 2 {
+		t.Fatalf("got %s after %d requests, want 200 after a retry", resp.Status, n.Load())
+	}
+	if delay < time.Second {
+		t.Fatalf("retried after %s, Retry-After asked for 1s", delay)
+	}
+}
+
+func TestNewClientProxyNone(t *testing.T) {
+	// прокси, к которому не подключиться: запрос через него бы упал
+	t.Setenv("HTTPS_PROXY", "http://127.0.0.1:1")
+	t.Setenv("https_proxy", "http://127.0.0.1:1")
+	srv, bundle := newTLSServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+
+	client := newTestClient(t, ClientOptions{CABundle: bundle, Proxy: ProxyNone})
+	if proxy := client.Transport.(*stallTransport).base.(*http.Transport).Proxy; proxy != nil {
+		t.Fatal("ProxyNone left a proxy function in the transport")
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request went through HTTPS_PROXY: %v", err)
+	}
+	resp.Body.Close()
+
+	// явный прокси, наоборот, используется
+	client = newTestClient(t, ClientOptions{CABundle: bundle, Proxy: "http://127.0.0.1:1"})
+	if resp, err := client.Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("explicit proxy was ignored")
+	} else if !strings.Contains(err.Error(), "proxy") {
+		t.Fatalf("got %v, want a proxy connection error", err)
+	}
+}
@@ -0,0 +1,273 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"sluhach/pkg/fs"
+)
+
+// partial — сведения о недокачанном архиве, лежат рядом с ним в *.json.
+// По ETag/Last-Modified сервер через If-Range решает, можно ли докачивать.
+type partial struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Size         int64  `json:"size,omitempty"`
+	// ChunkSize и Done заполняются при загрузке кусками: какие куски
+	// уже лежат в файле на своих местах.
+	ChunkSize int64  `json:"chunk_size,omitempty"`
+	Done      []bool `json:"done,omitempty"`
+}
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "sluhach")
+}
+
+func (m *Manager) partialPath(name string) string {
+	return filepath.Join(m.cacheDir, "downloads", name+".part")
+}
+
+func removePartial(path string) {
+	_ = os.Remove(path)
+	_ = os.Remove(path + ".json")
+}
+
+func readPartial(path string) *partial {
+	data, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil
+	}
+	var p partial
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil
+	}
+	return &p
+}
+
+func (p *partial) save(path string) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".json", data, 0o644)
+}
+
+// validator возвращает значение для If-Range. Слабый ETag для If-Range
+// не годится, тогда используется Last-Modified.
+func (p *partial) validator() string {
+	if p.ETag != "" && !strings.HasPrefix(p.ETag, "W/") {
+		return p.ETag
+	}
+	return p.LastModified
+}
+
+// parseContentRange разбирает "bytes 100-999/1000" в первый и последний
+// байты и размер архива; total = -1, если размер неизвестен
+// ("bytes 100-999/*").
+func parseContentRange(s string) (start, end, total int64, err error) {
+	s, ok := strings.CutPrefix(s, "bytes ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("unsupported Content-Range %q", s)
+	}
+	rng, size, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q: %w", s, err)
+	}
+	if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	if size == "*" {
+		return start, end, -1, nil
+	}
+	if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q: %w", s, err)
+	}
+	return start, end, total, nil
+}
+
+// download скачивает url в dst. Если от прошлой попытки остался кусок файла,
+// загрузка продолжается с его конца запросом Range/If-Range; если архив на
+// сервере изменился, сервер отдаёт его целиком и файл перезаписывается.
+// При обрыве соединения скачанное сохраняется. С WithConcurrency большой
+// архив качается несколькими запросами Range параллельно, см. downloadChunks.
+func (m *Manager) download(model, url, dst string) error {
+	err := m.downloadOnce(model, url, dst)
+	if errors.Is(err, errArchiveChanged) {
+		// куски от старой версии уже удалены — качаем заново, но только раз:
+		// архив, который меняется на каждый запрос, иначе качался бы по кругу
+		err = m.downloadOnce(model, url, dst)
+	}
+	if errors.Is(err, errArchiveChanged) {
+		return fmt.Errorf("failed to download model: %w: %w", ErrNetwork, err)
+	}
+	return err
+}
+
+func (m *Manager) downloadOnce(model, url, dst string) error {
+	if err := fs.CreateDirs(filepath.Dir(dst)); err != nil {
+		return fmt.Errorf("failed to create download dir: %w", err)
+	}
+
+	var offset int64
+	meta := readPartial(dst)
+	if meta != nil && meta.URL == url && meta.ChunkSize > 0 {
+		// прошлая загрузка шла кусками: файл уже полного размера
+		if info, err := os.Stat(dst); err == nil && info.Size() == meta.Size {
+			// место под архив уже занято, осталось проверить распаковку
+			if err := m.checkSpace(model, meta.Size, 0); err != nil {
+				return err
+			}
+			return m.downloadChunks(model, url, dst, meta)
+		}
+		removePartial(dst)
+		meta = nil
+	}
+	if info, err := os.Stat(dst); err == nil && meta != nil && meta.URL == url && meta.validator() != "" {
+		offset = info.Size()
+	}
+	if offset > 0 && offset == meta.Size {
+		// прошлая попытка докачала архив, но не успела его распаковать
+		return nil
+	}
+
+	// стандартные заголовки и заголовки репозитория
+	req, err := m.newRequest(http.MethodGet, url)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", meta.validator())
+	}
+
+	resp, err := m.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download model: %w: %w", ErrNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if offset == 0 {
+			// Range не отправлялся: сведений о загрузке ещё нет, и неясно,
+			// какую часть архива прислал сервер
+			return fmt.Errorf("failed to download model: %w: partial content for a request without Range", ErrNetwork)
+		}
+		start, _, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return fmt.Errorf("failed to resume download: %w: %w", ErrNetwork, err)
+		}
+		if start != offset {
+			removePartial(dst)
+			return fmt.Errorf("failed to resume download: %w: server resumed from %d instead of %d", ErrNetwork, start, offset)
+		}
+		if total > 0 {
+			meta.Size = total
+		}
+	case http.StatusOK:
+		// сервер не поддерживает Range или архив изменился — качаем заново
+		offset = 0
+		meta = &partial{
+			URL:          url,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Size:         resp.ContentLength,
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// локальный кусок не соответствует архиву на сервере
+		removePartial(dst)
+		return m.download(model, url, dst)
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrModelNotFound, url)
+	default:
+		return fmt.Errorf("failed to download model: %w: status %s", ErrNetwork, resp.Status)
+	}
+
+	// размер стал известен только сейчас, но ещё ничего не скачано
+	if err := m.checkSpace(model, meta.Size, meta.Size-offset); err != nil {
+		return err
+	}
+	if offset == 0 && m.parallel(resp, meta) {
+		// этот ответ не читаем: куски запрашиваются отдельно
+		resp.Body.Close()
+		return m.downloadChunks(model, url, dst, meta)
+	}
+
+	if err := meta.save(dst); err != nil {
+		return fmt.Errorf("failed to save download state: %w", err)
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if offset == 0 {
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(dst, flag, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open model archive: %w", err)
+	}
+	defer f.Close()
+
+	total := meta.Size
+	if total <= 0 {
+		total = -1
+	}
+	m.progress.Start(path.Base(url), offset, total)
+	downloaded, err := copyBody(f, resp.Body, offset, m.progress)
+	m.progress.Finish(err)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close model archive: %w", err)
+	}
+	if total > 0 && downloaded != total {
+		return fmt.Errorf("failed to download model: %w: got %d of %d bytes", ErrNetwork, downloaded, total)
+	}
+	return nil
+}
+
+// copyBody пишет тело ответа в файл и сообщает о ходе загрузки. Возвращает,
+// сколько байт архива лежит на диске с учётом offset.
+func copyBody(f *os.File, body io.Reader, offset int64, progress Progress) (int64, error) {
+	var (
+		downloaded = offset
+		buf        = make([]byte, 32*1024)
+	)
+	for {
+		n, _err := body.Read(buf)
+		if n > 0 {
+			if _, err := f.Write(buf[:n]); err != nil {
+				return downloaded, fmt.Errorf("failed to save model archive: %w", err)
+			}
+			downloaded += int64(n)
+			progress.Update(downloaded)
+		}
+		if errors.Is(_err, io.EOF) {
+			return downloaded, nil
+		}
+		if _err != nil {
+			return downloaded, fmt.Errorf("failed to read response body (%d bytes kept, run again to resume): %w: %w: %w", downloaded, ErrNetwork, errInterrupted, _err)
+		}
+	}
+}
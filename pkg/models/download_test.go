@@ -0,0 +1,219 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestManager создаёт Manager с временными каталогами и без повторов,
+// чтобы ошибки сервера доходили до теста сразу.
+func newTestManager(t *testing.T, opts ...Option) *Manager {
+	t.Helper()
+	return New(t.TempDir(), append([]Option{WithCacheDir(t.TempDir()), WithRetries(0)}, opts...)...)
+}
+
+// testArchive — содержимое архива, в котором каждый байт на своём месте
+// отличается от соседних, так что сдвиг при склейке будет заметен.
+func testArchive(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*7 + i/251)
+	}
+	return data
+}
+
+// requestLog запоминает заголовки Range и If-Range каждого запроса.
+type requestLog struct {
+	mu     sync.Mutex
+	ranges []string
+}
+
+func (l *requestLog) add(r *http.Request) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ranges = append(l.ranges, r.Header.Get("Range")+"|"+r.Header.Get("If-Range"))
+	return len(l.ranges)
+}
+
+func (l *requestLog) all() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.ranges...)
+}
+
+// serveArchive отдаёт data с ETag через http.ServeContent: Range, If-Range
+// и 416 обрабатывает стандартная библиотека.
+func serveArchive(w http.ResponseWriter, r *http.Request, etag string, data []byte) {
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "model.zip", time.Time{}, bytes.NewReader(data))
+}
+
+// writePartial кладёт недокачанный архив и его сведения, как после обрыва.
+func writePartial(t *testing.T, dst string, data []byte, meta partial) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := meta.save(dst); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertFile(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("archive differs: got %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func TestDownloadResumesAfterDrop(t *testing.T) {
+	data := testArchive(300 << 10)
+	var log requestLog
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if log.add(r) == 1 {
+			// первая попытка: половина тела, затем обрыв соединения
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(data[:len(data)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		serveArchive(w, r, `"v1"`, data)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t)
+	dst := m.partialPath("model.zip")
+	url := srv.URL + "/model.zip"
+
+	err := m.download("model", url, dst)
+	if !errors.Is(err, errInterrupted) || !errors.Is(err, ErrNetwork) {
+		t.Fatalf("first attempt: got %v, want interrupted network error", err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil || info.Size() == 0 {
+		t.Fatalf("partial archive was not kept: %v", err)
+	}
+
+	if err := m.download("model", url, dst); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	assertFile(t, dst, data)
+
+	want := fmt.Sprintf("bytes=%d-|\"v1\"", info.Size())
+	if got := log.all(); len(got) != 2 || got[1] != want {
+		t.Fatalf("requests %q, want second to be %q", got, want)
+	}
+}
+
+func TestDownloadRestartsWhenETagChanged(t *testing.T) {
+	old, data := testArchive(100<<10), bytes.Repeat([]byte("new"), 50<<10)
+	var log requestLog
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.add(r)
+		// If-Range со старым ETag не совпадает — ServeContent отдаёт 200
+		serveArchive(w, r, `"v2"`, data)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t)
+	dst := m.partialPath("model.zip")
+	url := srv.URL + "/model.zip"
+	writePartial(t, dst, old[:40<<10], partial{URL: url, ETag: `"v1"`, Size: int64(len(old))})
+
+	if err := m.download("model", url, dst); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, dst, data)
+	if meta := readPartial(dst); meta == nil || meta.ETag != `"v2"` {
+		t.Fatalf("download state not updated: %+v", meta)
+	}
+	if got := log.all(); len(got) != 1 || !strings.HasPrefix(got[0], "bytes=40960-|") {
+		t.Fatalf("requests %q, want one ranged request", got)
+	}
+}
+
+func TestDownloadRestartsAfter416(t *testing.T) {
+	data := testArchive(64 << 10)
+	var log requestLog
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.add(r)
+		serveArchive(w, r, `"v1"`, data)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t)
+	dst := m.partialPath("model.zip")
+	url := srv.URL + "/model.zip"
+	// локальный кусок длиннее архива на сервере: Range за его концом
+	writePartial(t, dst, testArchive(80<<10), partial{URL: url, ETag: `"v1"`, Size: 100 << 10})
+
+	if err := m.download("model", url, dst); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, dst, data)
+	if got := log.all(); len(got) != 2 || got[1] != "|" {
+		t.Fatalf("requests %q, want a ranged request and a full restart", got)
+	}
+}
+
+func TestDownloadRejectsWrongContentRange(t *testing.T) {
+	data := testArchive(64 << 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// сервер игнорирует смещение из Range и отдаёт архив с начала
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(data)-1, len(data)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t)
+	dst := m.partialPath("model.zip")
+	url := srv.URL + "/model.zip"
+	writePartial(t, dst, data[:16<<10], partial{URL: url, ETag: `"v1"`, Size: int64(len(data))})
+
+	err := m.download("model", url, dst)
+	if !errors.Is(err, ErrNetwork) || !strings.Contains(err.Error(), "resumed from 0 instead of 16384") {
+		t.Fatalf("got %v, want a resume offset error", err)
+	}
+	if _, err := os.Stat(dst); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("partial archive kept after a wrong range: %v", err)
+	}
+}
+
+func TestDownloadRejectsUnrequestedPartialContent(t *testing.T) {
+	data := testArchive(64 << 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 206 на запрос без Range
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(data)-1, len(data)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t)
+	err := m.download("model", srv.URL+"/model.zip", m.partialPath("model.zip"))
+	if !errors.Is(err, ErrNetwork) || !strings.Contains(err.Error(), "without Range") {
+		t.Fatalf("got %v, want a protocol error", err)
+	}
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sluhach/pkg/fs"
+)
+
+// archiveExts — расширения архивов, которые отрезаются от имени файла,
+// длинные раньше коротких. Сам формат fs.Extract определяет по содержимому.
+var archiveExts = []string{".tar.gz", ".tgz", ".tar.xz", ".txz", ".tar.zst", ".tzst", ".tar", ".zip"}
+
+// ErrInvalidName — имя модели не годится в имя каталога в modelDir.
+var ErrInvalidName = errors.New("invalid model name")
+
+// ImportOptions настраивает Import.
+type ImportOptions struct {
+	// Name — имя модели; по умолчанию берётся из архива или каталога.
+	Name string
+	// Link — вместо копирования каталога создать на него символическую ссылку.
+	Link bool
+	// Force — заменить уже установленную модель.
+	Force bool
+}
+
+// Import устанавливает модель из локального архива (zip, tar, tar.gz,
+// tar.xz, tar.zst) или каталога через тот же staging и ту же проверку
+// структуры, что и Load.
+// Возвращает имя установленной модели.
+func (m *Manager) Import(src string, opts ImportOptions) (string, error) {
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", src, err)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to import model: %w", err)
+	}
+	if info.IsDir() {
+		if opts.Name == "" {
+			opts.Name = filepath.Base(src)
+		}
+		if err := checkName(opts.Name); err != nil {
+			return "", err
+		}
+		if opts.Link {
+			return opts.Name, m.link(src, opts)
+		}
+		return opts.Name, m.importDir(src, opts)
+	}
+	if opts.Link {
+		return "", fmt.Errorf("only a directory can be linked, %s is a file", src)
+	}
+	if opts.Name != "" {
+		if err := checkName(opts.Name); err != nil {
+			return "", err
+		}
+	}
+	return m.importArchive(src, info, opts)
+}
+
+// checkName не пускает имена, с которыми модель оказалась бы вне modelDir
+// или среди служебных файлов: те начинаются с точки (.staging-, .trash-,
+// .index.json), и Cleanup удаляет часть из них.
+func checkName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%w %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// checkInstalled не даёт без force перекрыть установленную модель.
+func (m *Manager) checkInstalled(model string, force bool) error {
+	installed, err := m.find(model)
+	if err != nil {
+		return err
+	}
+	if installed != nil && !force {
+		return fmt.Errorf("%w: %s (%s)", ErrModelExists, model, installed.Path)
+	}
+	return nil
+}
+
+func (m *Manager) importArchive(src string, info os.FileInfo, opts ImportOptions) (string, error) {
+	name := filepath.Base(src)
+	if i := slices.IndexFunc(archiveExts, func(ext string) bool { return strings.HasSuffix(name, ext) }); i >= 0 {
+		name = strings.TrimSuffix(name, archiveExts[i])
+	}
+	// с явным именем квота считается для модели, которую заменит импорт
+	if opts.Name != "" {
+		name = opts.Name
+	}
+
+	if err := m.checkSpace(name, info.Size(), 0); err != nil {
+		return "", err
+	}
+	staging, err := m.stage(name)
+	if err != nil {
+		return "", err
+	}
+	defer fs.Remove(staging)
+
+	if err := fs.Extract(src, staging); err != nil {
+		return "", fmt.Errorf("failed to extract model: %w", err)
+	}
+
+	// имя каталога внутри архива надёжнее имени файла, который могли переименовать
+	if opts.Name == "" {
+		opts.Name = name
+		if root := modelRoot(staging); root != staging {
+			opts.Name = filepath.Base(root)
+		}
+		if err := checkName(opts.Name); err != nil {
+			return "", err
+		}
+	}
+	if err := m.checkInstalled(opts.Name, opts.Force); err != nil {
+		return "", err
+	}
+
+	sum, err := m.verifyArchive(opts.Name, src, src)
+	if err != nil {
+		return "", err
+	}
+	return opts.Name, m.install(staging, &Manifest{
+		Name:          opts.Name,
+		Source:        src,
+		ArchiveSHA256: sum,
+		Size:          info.Size(),
+		LastModified:  info.ModTime().UTC().Format(http.TimeFormat),
+	}, opts.Force)
+}
+
+func (m *Manager) importDir(src string, opts ImportOptions) error {
+	if err := Validate(src); err != nil {
+		return fmt.Errorf("failed to import %s: %w", src, err)
+	}
+	if err := m.checkInstalled(opts.Name, opts.Force); err != nil {
+		return err
+	}
+	// копия займёт в modelDir столько же, сколько исходный каталог
+	if err := m.checkUnpacked(opts.Name, diskSize(src), 0); err != nil {
+		return err
+	}
+
+	staging, err := m.stage(opts.Name)
+	if err != nil {
+		return err
+	}
+	defer fs.Remove(staging)
+
+	if err := fs.CopyDir(src, filepath.Join(staging, opts.Name)); err != nil {
+		return fmt.Errorf("failed to copy model: %w", err)
+	}
+	return m.install(staging, &Manifest{
+		Name:   opts.Name,
+		Source: src,
+	}, opts.Force)
+}
+
+// link ставит ссылку на каталог модели. Манифест не пишется: каталог
+// чужой, и менять его мы не вправе, поэтому verify такую модель пропускает.
+// Место в modelDir ссылка не занимает и в квоту не входит (см. owned),
+// поэтому checkSpace здесь не нужен.
+func (m *Manager) link(src string, opts ImportOptions) error {
+	if err := Validate(src); err != nil {
+		return fmt.Errorf("failed to import %s: %w", src, err)
+	}
+	if err := m.checkInstalled(opts.Name, opts.Force); err != nil {
+		return err
+	}
+	if err := fs.CreateDirs(m.modelDir); err != nil {
+		return fmt.Errorf("failed to create model dir: %w", err)
+	}
+
+	// ссылка создаётся рядом и переносится rename'ом, как и обычная модель
+	target := filepath.Join(m.modelDir, opts.Name)
+	tmp := filepath.Join(m.modelDir, fmt.Sprintf("%s%d-%s", stagingPrefix, os.Getpid(), opts.Name))
+	if err := os.Symlink(src, tmp); err != nil {
+		return fmt.Errorf("failed to link model: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	return replace(tmp, target)
+}
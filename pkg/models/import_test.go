@@ -0,0 +1,125 @@
+package models
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testModelDir создаёт каталог модели распознавания, в которой am/final.mdl
+// занимает size байт.
+func testModelDir(t *testing.T, name string, size int) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), name)
+	for _, sub := range []string{"am", "conf", "graph"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "am", "final.mdl"), make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestImportDirRespectsQuota(t *testing.T) {
+	src := testModelDir(t, "vosk-model-big", 64<<10)
+
+	m := newTestManager(t, WithQuota(16<<10))
+	if _, err := m.Import(src, ImportOptions{}); !errors.Is(err, ErrNoSpace) {
+		t.Fatalf("got %v, want quota error", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.modelDir, "vosk-model-big")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("model installed over quota: %v", err)
+	}
+
+	// копия размером с исходный каталог, без запаса на распаковку
+	m = newTestManager(t, WithQuota(64<<10))
+	if _, err := m.Import(src, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testModelZip упаковывает модель без сжатия, чтобы размер архива был
+// предсказуем.
+func testModelZip(t *testing.T, name string, size int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name+".zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, dir := range []string{"conf/", "graph/"} {
+		if _, err := zw.Create(name + "/" + dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name + "/am/final.mdl", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(make([]byte, size)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportArchiveQuotaUsesName(t *testing.T) {
+	m := newTestManager(t)
+	if _, err := m.Import(testModelDir(t, "other", 64<<10), ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// 64K модели other плюс до 3×8K распакованного архива в квоту не
+	// влезают; но other заменяется и в занятом месте не считается
+	m.quota = 70 << 10
+	archive := testModelZip(t, "xx", 8<<10)
+	name, err := m.Import(archive, ImportOptions{Name: "other", Force: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "other" {
+		t.Fatalf("installed as %s, want other", name)
+	}
+
+	// отказ называет модель, под именем которой идёт импорт
+	m.quota = 16 << 10
+	_, err = m.Import(archive, ImportOptions{Name: "third"})
+	if !errors.Is(err, ErrNoSpace) || !strings.Contains(err.Error(), "third") {
+		t.Fatalf("got %v, want quota error for third", err)
+	}
+}
+
+func TestImportRejectsBadNames(t *testing.T) {
+	src := testModelDir(t, "vosk-model-small", 1<<10)
+	archive := testModelZip(t, "vosk-model-small", 1<<10)
+	for _, name := range []string{"../x", "a/b", `a\b`, ".", "..", ".staging-1-x", ".trash-1-x", ".hidden"} {
+		for _, opts := range []ImportOptions{{Name: name}, {Name: name, Link: true}} {
+			m := newTestManager(t)
+			if _, err := m.Import(src, opts); !errors.Is(err, ErrInvalidName) {
+				t.Errorf("dir %+v: got %v, want invalid name", opts, err)
+			}
+			// отказ раньше любой записи: даже staging не создаётся
+			if entries, _ := os.ReadDir(m.modelDir); len(entries) > 0 {
+				t.Errorf("dir %+v: model dir has %s", opts, entries[0].Name())
+			}
+		}
+		m := newTestManager(t)
+		if _, err := m.Import(archive, ImportOptions{Name: name}); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("archive %q: got %v, want invalid name", name, err)
+		}
+	}
+
+	// имя каталога с точкой тоже не годится, если --name не задан
+	hidden := testModelDir(t, ".model", 1<<10)
+	if _, err := newTestManager(t).Import(hidden, ImportOptions{}); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("got %v, want invalid name", err)
+	}
+}
@@ -0,0 +1,478 @@
+package models
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sluhach/pkg/fs"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// indexName — кэш разобранного каталога в modelDir.
+const indexName = ".index.json"
+
+const defaultIndexTTL = 24 * time.Hour
+
+// CachePolicy — откуда Avail берёт каталог.
+type CachePolicy int
+
+const (
+	// CacheDefault — кэш, пока он свежее TTL, иначе сеть; если сеть
+	// недоступна, кэш любой давности.
+	CacheDefault CachePolicy = iota
+	// CacheOffline — только кэш, без обращения к сети.
+	CacheOffline
+	// CacheRefresh — только сеть, кэш перезаписывается.
+	CacheRefresh
+)
+
+var ErrNoIndexCache = errors.New("no cached model catalog, run without --offline first")
+
+// ModelIndex — каталог моделей, доступных для скачивания, в разобранном
+// виде. Хранится в JSON, чтобы не разбирать HTML при каждом обращении.
+type ModelIndex struct {
+	Source    string    `json:"source"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Models    []Model   `json:"models"`
+	// Repos — адреса репозиториев Manager, для которых собран кэш: после
+	// смены списка репозиториев кэш не годится.
+	Repos []string `json:"repos,omitempty"`
+	// Cached — каталог взят из кэша, а не из сети.
+	Cached bool `json:"-"`
+}
+
+// WithIndexTTL задаёт, сколько кэш каталога считается свежим.
+func WithIndexTTL(ttl time.Duration) Option {
+	return func(m *Manager) {
+		m.indexTTL = ttl
+	}
+}
+
+// Avail возвращает каталог доступных моделей согласно policy.
+func (m *Manager) Avail(policy CachePolicy) (*ModelIndex, error) {
+	path := filepath.Join(m.modelDir, indexName)
+	cached, err := ReadIndex(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		// битый кэш просто перекачиваем
+		cached = nil
+	}
+	if cached != nil && !slices.Equal(cached.Repos, m.repoURLs()) {
+		// кэш собран для другого списка репозиториев
+		cached = nil
+	}
+	if cached != nil {
+		cached.Cached = true
+	}
+
+	switch policy {
+	case CacheOffline:
+		if cached == nil {
+			return nil, ErrNoIndexCache
+		}
+		return cached, nil
+	case CacheDefault:
+		if cached != nil && time.Since(cached.FetchedAt) < m.indexTTL {
+			return cached, nil
+		}
+	}
+
+	index, err := m.Index()
+	if errors.Is(err, ErrNetwork) && cached != nil && policy == CacheDefault {
+		return cached, nil
+	}
+	return index, err
+}
+
+// Filter — условия отбора моделей каталога; пустые поля не ограничивают.
+type Filter struct {
+	// Lang — код (en, en-us) или название языка (English), без учёта регистра.
+	Lang string
+	// MaxSize — максимальный размер архива в байтах.
+	MaxSize int64
+	// Search — подстрока в имени или описании, без учёта регистра.
+	Search string
+	// Sort — порядок: name, size, lang; пустой — порядок каталога.
+	Sort string
+}
+
+var SortKeys = []string{"name", "size", "lang"}
+
+// Filter отбирает и сортирует модели каталога.
+func (i *ModelIndex) Filter(f Filter) ([]Model, error) {
+	var (
+		lang   = strings.ToLower(f.Lang)
+		search = strings.ToLower(f.Search)
+		models []Model
+	)
+	for _, m := range i.Models {
+		if lang != "" &&
+			m.LangCode != lang &&
+			!strings.HasPrefix(m.LangCode, lang+"-") &&
+			!strings.EqualFold(m.Lang, lang) {
+			continue
+		}
+		if f.MaxSize > 0 && (m.SizeBytes == 0 || m.SizeBytes > f.MaxSize) {
+			continue
+		}
+		if search != "" &&
+			!strings.Contains(strings.ToLower(m.Name), search) &&
+			!strings.Contains(strings.ToLower(m.Desc), search) {
+			continue
+		}
+		models = append(models, m)
+	}
+
+	var compare func(a, b Model) int
+	switch f.Sort {
+	case "":
+		return models, nil
+	case "name":
+		compare = func(a, b Model) int { return strings.Compare(a.Name, b.Name) }
+	case "size":
+		compare = func(a, b Model) int { return cmp.Compare(a.SizeBytes, b.SizeBytes) }
+	case "lang":
+		compare = func(a, b Model) int { return strings.Compare(a.LangCode, b.LangCode) }
+	default:
+		return nil, fmt.Errorf("unknown sort key %q, expected one of %s", f.Sort, strings.Join(SortKeys, ", "))
+	}
+	slices.SortStableFunc(models, compare)
+	return models, nil
+}
+
+// Age — возраст каталога.
+func (i *ModelIndex) Age() time.Duration {
+	return time.Since(i.FetchedAt)
+}
+
+// Stale сообщает, что каталог старше TTL менеджера, то есть получен из кэша
+// из‑за недоступности сети.
+func (m *Manager) Stale(index *ModelIndex) bool {
+	return index.Cached && index.Age() >= m.indexTTL
+}
+
+// колонки таблицы моделей; ищутся по заголовку, а если его нет — по позиции
+const (
+	colName = iota
+	colSize
+	colWER
+	colNotes
+	colLicense
+	colCount
+)
+
+var columnHeaders = [colCount]string{
+	colName:    "model",
+	colSize:    "size",
+	colWER:     "error",
+	colNotes:   "notes",
+	colLicense: "license",
+}
+
+// ParseIndex разбирает HTML‑страницу каталога моделей Vosk
+// (https://alphacephei.com/vosk/models): строки с одной заполненной ячейкой
+// задают язык для следующих за ними моделей.
+func ParseIndex(r io.Reader, source string) (*ModelIndex, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	table := doc.Find("table.table.table-bordered").First()
+	if table.Length() == 0 {
+		return nil, fmt.Errorf("models table not found")
+	}
+
+	// получаем tbody из таблицы (если он есть)
+	tbody := table.Find("tbody")
+	if tbody.Length() == 0 {
+		return nil, fmt.Errorf("models content not found")
+	}
+
+	columns := [colCount]int{colName, colSize, colWER, colNotes, colLicense}
+	table.Find("thead th").Each(func(i int, th *goquery.Selection) {
+		header := strings.ToLower(th.Text())
+		for col, name := range columnHeaders {
+			if strings.Contains(header, name) {
+				columns[col] = i
+			}
+		}
+	})
+
+	var (
+		models []Model
+		lang   string
+	)
+
+	tbody.Find("tr").Each(func(i int, tr *goquery.Selection) {
+		var (
+			cells  []string
+			links  []string
+			filled int
+		)
+		tr.Find("td").Each(func(i int, td *goquery.Selection) {
+			// несколько значений в ячейке (WER на разных наборах) разделены <br>
+			td.Find("br").ReplaceWithHtml(" ")
+			text := strings.Join(strings.Fields(td.Text()), " ")
+			href, _ := td.Find("a").First().Attr("href")
+			cells = append(cells, text)
+			links = append(links, href)
+			if text != "" {
+				filled++
+			}
+		})
+		cell := func(col int) string {
+			if columns[col] < len(cells) {
+				return cells[columns[col]]
+			}
+			return ""
+		}
+
+		name, size := cell(colName), cell(colSize)
+		if name == "" || size == "" {
+			if filled == 1 {
+				for _, text := range cells {
+					if text != "" {
+						lang = text
+					}
+				}
+			}
+			return
+		}
+
+		sizeBytes, _ := ParseSize(size)
+		model := Model{
+			Lang:      lang,
+			LangCode:  LangCode(name),
+			Name:      name,
+			Size:      size,
+			SizeBytes: sizeBytes,
+			WER:       cell(colWER),
+			Desc:      cell(colNotes),
+			License:   cell(colLicense),
+		}
+		if columns[colName] < len(links) && links[columns[colName]] != "" {
+			model.URL = resolveURL(source, links[columns[colName]])
+		}
+		models = append(models, model)
+	})
+
+	if len(models) == 0 {
+		return nil, fmt.Errorf("not found any model")
+	}
+
+	return &ModelIndex{
+		Source:    source,
+		FetchedAt: time.Now().UTC(),
+		Models:    models,
+	}, nil
+}
+
+// resolveURL превращает относительную ссылку из каталога в абсолютную.
+func resolveURL(base, href string) string {
+	_base, err := url.Parse(base)
+	if err != nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return _base.ResolveReference(ref).String()
+}
+
+// ReadIndex читает каталог, ранее сохранённый Save.
+func ReadIndex(path string) (*ModelIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var index ModelIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse index %s: %w", path, err)
+	}
+	return &index, nil
+}
+
+// Save записывает каталог в JSON; параллельный читатель не увидит его
+// наполовину записанным.
+func (i *ModelIndex) Save(path string) error {
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	if err := fs.WriteFileAtomic(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	return nil
+}
+
+// Index скачивает и разбирает каталог моделей из первого доступного
+// репозитория и сохраняет его JSON‑копию в modelDir.
+func (m *Manager) Index() (*ModelIndex, error) {
+	var errs []error
+	for _, repo := range m.repos {
+		index, err := m.indexFrom(repo)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("repo %s: %w", repo.Name, err))
+			continue
+		}
+		index.Repos = m.repoURLs()
+		if err := index.Save(filepath.Join(m.modelDir, indexName)); err != nil {
+			return nil, err
+		}
+		return index, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// repoURLs — адреса репозиториев в порядке перебора.
+func (m *Manager) repoURLs() []string {
+	urls := make([]string, len(m.repos))
+	for i, repo := range m.repos {
+		urls[i] = repo.URL
+	}
+	return urls
+}
+
+// indexFrom читает каталог репозитория. HTTP‑репозиторий отдаёт страницу
+// в формате alphacephei.com или JSON, сохранённый Save; локальный —
+// index.json либо просто архивы *.zip.
+func (m *Manager) indexFrom(repo Repo) (*ModelIndex, error) {
+	if dir, ok := repo.dir(); ok {
+		return readRepoDir(dir, repo.URL)
+	}
+
+	body, header, err := m.open(repo.URL)
+	if errors.Is(err, ErrModelNotFound) {
+		return nil, fmt.Errorf("%w: no model catalog at %s", ErrNetwork, repo.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	if !strings.Contains(header.Get("Content-Type"), "json") {
+		return ParseIndex(body, repo.URL)
+	}
+	var index ModelIndex
+	if err := json.NewDecoder(body).Decode(&index); err != nil {
+		return nil, fmt.Errorf("failed to parse index %s: %w", repo.URL, err)
+	}
+	index.Source = repo.URL
+	index.FetchedAt = time.Now().UTC()
+	return &index, nil
+}
+
+// readRepoDir собирает каталог локального репозитория.
+func readRepoDir(dir, source string) (*ModelIndex, error) {
+	index, err := ReadIndex(filepath.Join(dir, "index.json"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if index == nil {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read repo dir: %w", err)
+		}
+		index = &ModelIndex{}
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(entry.Name(), ".zip")
+			if !ok || entry.IsDir() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			index.Models = append(index.Models, Model{
+				LangCode:  LangCode(name),
+				Name:      name,
+				Size:      FormatSize(info.Size()),
+				SizeBytes: info.Size(),
+				URL:       filepath.Join(dir, entry.Name()),
+			})
+		}
+	}
+	index.Source = source
+	index.FetchedAt = time.Now().UTC()
+	return index, nil
+}
+
+// LangCode выводит код языка из имени модели:
+// vosk-model-small-en-us-0.15 → en-us, vosk-model-ru-0.42 → ru.
+func LangCode(name string) string {
+	name = strings.TrimPrefix(name, "vosk-model-")
+	name = strings.TrimPrefix(name, "small-")
+	parts := strings.Split(name, "-")
+	code := parts[0]
+	if len(parts) > 1 && len(parts[1]) == 2 && strings.Trim(parts[1], "abcdefghijklmnopqrstuvwxyz") == "" {
+		code += "-" + parts[1]
+	}
+	return code
+}
+
+// ParseSize переводит размеры вида "45M", "1.8G", "128Mb", "2 GiB" в байты.
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+	s = strings.TrimSuffix(s, "B")
+	s = strings.TrimSuffix(s, "I")
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	var shift uint
+	switch s[len(s)-1] {
+	case 'K':
+		shift = 10
+	case 'M':
+		shift = 20
+	case 'G':
+		shift = 30
+	case 'T':
+		shift = 40
+	}
+	if shift > 0 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	// inf и NaN ParseFloat принимает, но размером они не являются
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	n = math.Round(n * float64(int64(1)<<shift))
+	// float64(math.MaxInt64) округляется вверх до 2^63, поэтому >=
+	if n >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return int64(n), nil
+}
+
+// FormatSize печатает размер в байтах в том же виде, что и каталог: 45M, 1.8G.
+func FormatSize(n int64) string {
+	const units = "KMGT"
+	if n < 1024 {
+		return strconv.FormatInt(n, 10)
+	}
+	v, i := float64(n)/1024, 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	if v >= 10 {
+		return fmt.Sprintf("%.0f%c", v, units[i])
+	}
+	return fmt.Sprintf("%.1f%c", v, units[i])
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "перезаписать golden-файлы")
+
+// TestParseIndexGolden сверяет разбор сохранённой страницы каталога с
+// эталоном; после намеренных изменений разбора эталон обновляется
+// go test -run ParseIndex -update.
+func TestParseIndexGolden(t *testing.T) {
+	const source = "https://alphacephei.com/vosk/models"
+	f, err := os.Open(filepath.Join("testdata", "models.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	index, err := ParseIndex(f, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.MarshalIndent(index.Models, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	golden := filepath.Join("testdata", "index.golden.json")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("parsed catalog differs from %s:\n%s", golden, got)
+	}
+}
+
+func TestParseIndexColumns(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "models.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	index, err := ParseIndex(f, "https://alphacephei.com/vosk/models")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Model{
+		"vosk-model-en-us-0.22": {
+			Lang: "English", LangCode: "en-us", SizeBytes: 1932735283,
+			WER:     "5.69 (librispeech test-clean) 6.05 (tedlium)",
+			License: "Apache 2.0",
+			URL:     "https://alphacephei.com/vosk/models/vosk-model-en-us-0.22.zip",
+		},
+		// относительная ссылка разрешается от адреса страницы
+		"vosk-model-small-ru-0.22": {
+			Lang: "Russian", LangCode: "ru", SizeBytes: 45 << 20,
+			WER:     "22.71 (openstt audiobooks)",
+			License: "Apache 2.0",
+			URL:     "https://alphacephei.com/vosk/vosk-model-small-ru-0.22.zip",
+		},
+		// ссылка от корня сайта
+		"vosk-model-ru-0.42": {
+			Lang: "Russian", LangCode: "ru", SizeBytes: 1932735283,
+			WER:     "4.5 (our audiobooks) 11.1 (open_stt youtube)",
+			License: "Apache 2.0",
+			URL:     "https://alphacephei.com/vosk/models/vosk-model-ru-0.42.zip",
+		},
+		"vosk-model-fa-0.5": {
+			Lang: "Farsi", LangCode: "fa", SizeBytes: 1 << 30,
+			License: "CC-BY-NC-SA 4.0",
+			URL:     "https://alphacephei.com/vosk/models/vosk-model-fa-0.5.zip",
+		},
+	}
+
+	seen := 0
+	for _, m := range index.Models {
+		w, ok := want[m.Name]
+		if !ok {
+			continue
+		}
+		seen++
+		if m.Lang != w.Lang || m.LangCode != w.LangCode || m.SizeBytes != w.SizeBytes ||
+			m.WER != w.WER || m.License != w.License || m.URL != w.URL {
+			t.Errorf("%s:\n got %+v\nwant %+v", m.Name, m, w)
+		}
+	}
+	if seen != len(want) {
+		t.Fatalf("found %d of %d expected models in %d parsed", seen, len(want), len(index.Models))
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"45M", 45 << 20, true},
+		{"1.8G", 1932735283, true},
+		{"128Mb", 128 << 20, true},
+		{"2 GiB", 2 << 30, true},
+		{"512", 512, true},
+		{"", 0, false},
+		{"-1G", 0, false},
+		{"abc", 0, false},
+		// ParseFloat принимает их, но размером они не являются
+		{"inf", 0, false},
+		{"+Inf", 0, false},
+		{"NaN", 0, false},
+		{"1e30G", 0, false},
+		{"8T", 8 << 40, true},
+		{"9223372036854775807", 0, false},
+	}
+	for _, tc := range cases {
+		got, err := ParseSize(tc.in)
+		if tc.ok && (err != nil || got != tc.want) {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", tc.in, got, err, tc.want)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("ParseSize(%q) = %d, want error", tc.in, got)
+		}
+	}
+}
+
+func TestAvailIgnoresCacheOfOtherRepos(t *testing.T) {
+	serve := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(ModelIndex{Models: []Model{{Name: name}}})
+		}))
+	}
+	one, two := serve("vosk-model-one"), serve("vosk-model-two")
+	defer one.Close()
+	defer two.Close()
+
+	dir := t.TempDir()
+	avail := func(repo *httptest.Server, policy CachePolicy) (*ModelIndex, error) {
+		m := New(dir, WithCacheDir(t.TempDir()), WithRetries(0), WithRepos(Repo{Name: "r", URL: repo.URL}))
+		return m.Avail(policy)
+	}
+
+	if _, err := avail(one, CacheDefault); err != nil {
+		t.Fatal(err)
+	}
+	index, err := avail(two, CacheDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Cached || len(index.Models) != 1 || index.Models[0].Name != "vosk-model-two" {
+		t.Fatalf("got %+v, want the catalog of the new repo", index)
+	}
+
+	// свежий кэш того же списка репозиториев берётся без сети
+	if index, err = avail(two, CacheOffline); err != nil || !index.Cached {
+		t.Fatalf("got %+v, %v, want the cached catalog", index, err)
+	}
+	if _, err := avail(one, CacheOffline); !errors.Is(err, ErrNoIndexCache) {
+		t.Fatalf("got %v, want no cache for another repo", err)
+	}
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"sluhach/pkg/fs"
+)
+
+// sampleRateConfs — файлы, где Kaldi‑модели указывают частоту
+// дискретизации (--sample-frequency), в порядке поиска.
+var sampleRateConfs = []string{"conf/mfcc.conf", "conf/model.conf"}
+
+// rescoreDirs — каталоги с дополнительными графами для перескоринга.
+var rescoreDirs = []string{"rnnlm", "rescore"}
+
+// inspect дополняет модель сведениями с диска: размер, язык, частота
+// дискретизации, графы перескоринга, дата установки и проблемы структуры.
+func inspect(model *Model) {
+	// модель, поставленная через import --link, — ссылка на каталог
+	root, err := filepath.EvalSymlinks(model.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		model.Invalid = "broken link"
+		return
+	}
+	if err != nil {
+		model.Invalid = err.Error()
+		return
+	}
+
+	if strings.HasPrefix(model.Name, "vosk-model-") {
+		model.LangCode = LangCode(model.Name)
+	}
+	model.SizeBytes = diskSize(root)
+	model.Size = FormatSize(model.SizeBytes)
+	model.SampleRate = sampleRate(root)
+	for _, dir := range rescoreDirs {
+		if err := fs.Exists(filepath.Join(root, dir)); err == nil {
+			model.Rescore = append(model.Rescore, dir)
+		}
+	}
+	if manifest, err := readManifest(root); err == nil {
+		model.InstalledAt = manifest.InstalledAt
+	} else if info, err := os.Stat(root); err == nil {
+		model.InstalledAt = info.ModTime().UTC()
+	}
+	model.LastUsed = lastUsed(model)
+	if err := Validate(root); err != nil {
+		model.Invalid = strings.TrimPrefix(err.Error(), ErrInvalidModel.Error()+": ")
+	}
+}
+
+// diskSize суммирует размеры файлов модели; ошибки чтения пропускаются.
+func diskSize(root string) int64 {
+	var size int64
+	_ = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// sampleRate ищет --sample-frequency в конфигурации модели; 0 — не указана.
+func sampleRate(root string) int {
+	for _, name := range sampleRateConfs {
+		f, err := os.Open(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			value, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "--sample-frequency=")
+			if !ok {
+				continue
+			}
+			// значение бывает и дробным: 16000.0
+			if rate, err := strconv.ParseFloat(value, 64); err == nil && rate > 0 {
+				f.Close()
+				return int(rate)
+			}
+		}
+		f.Close()
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"sluhach/pkg/fs"
+)
+
+// stagingPrefix — временные каталоги распаковки внутри modelDir. Они лежат
+// на той же файловой системе, поэтому готовая модель переносится rename'ом.
+// trashPrefix — старая версия модели на время переустановки.
+const (
+	stagingPrefix = ".staging-"
+	trashPrefix   = ".trash-"
+)
+
+var ErrModelExists = errors.New("model already installed")
+
+var ErrInvalidModel = errors.New("invalid model layout")
+
+// layouts — обязательные файлы и каталоги для известных типов моделей Vosk.
+var layouts = [][]string{
+	// модель распознавания
+	{"am/final.mdl", "conf", "graph"},
+	// модель спикеров (vosk-model-spk)
+	{"final.ext.raw", "mean.vec", "transform.mat"},
+}
+
+// Validate проверяет, что в каталоге лежит модель Vosk известной структуры.
+func Validate(dir string) error {
+	var missing []string
+	for _, layout := range layouts {
+		missing = missing[:0]
+		for _, item := range layout {
+			if err := fs.Exists(filepath.Join(dir, item)); err != nil {
+				missing = append(missing, item)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+	}
+	// сообщаем о недостающем для модели распознавания как самой частой
+	missing = missing[:0]
+	for _, item := range layouts[0] {
+		if err := fs.Exists(filepath.Join(dir, item)); err != nil {
+			missing = append(missing, item)
+		}
+	}
+	return fmt.Errorf("%w: missing %s", ErrInvalidModel, strings.Join(missing, ", "))
+}
+
+// stage создаёт каталог для распаковки; pid в имени позволяет Cleanup
+// отличить брошенный каталог от каталога работающего процесса.
+func (m *Manager) stage(model string) (string, error) {
+	if err := fs.CreateDirs(m.modelDir); err != nil {
+		return "", fmt.Errorf("failed to create model dir: %w", err)
+	}
+	dir, err := os.MkdirTemp(m.modelDir, fmt.Sprintf("%s%d-%s-", stagingPrefix, os.Getpid(), model))
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging dir: %w", err)
+	}
+	return dir, nil
+}
+
+// modelRoot находит корень модели в распакованном архиве: архивы Vosk
+// содержат один каталог верхнего уровня, но бывают и «плоские».
+func modelRoot(staging string) string {
+	if Validate(staging) == nil {
+		return staging
+	}
+	entries, err := os.ReadDir(staging)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return staging
+	}
+	return filepath.Join(staging, entries[0].Name())
+}
+
+// install проверяет распакованную модель, пишет манифест и атомарно
+// переносит её в modelDir/<model>. При force установленная версия
+// заменяется: сначала убирается в сторону, после успешного переноса удаляется.
+func (m *Manager) install(staging string, manifest *Manifest, force bool) error {
+	model := manifest.Name
+	root := modelRoot(staging)
+	if err := Validate(root); err != nil {
+		return fmt.Errorf("failed to install %s: %w", model, err)
+	}
+	if err := writeManifest(root, manifest); err != nil {
+		return err
+	}
+	// «плоский» архив: корнем модели становится сам staging, созданный с 0700
+	if root == staging {
+		if err := os.Chmod(root, 0o755); err != nil {
+			return fmt.Errorf("failed to set model dir permissions: %w", err)
+		}
+	}
+
+	target := filepath.Join(m.modelDir, model)
+	if err := fs.Exists(target); err == nil && !force {
+		return fmt.Errorf("%w: %s", ErrModelExists, model)
+	}
+	return replace(root, target)
+}
+
+// replace переносит src на место target. Прежний target сначала
+// отодвигается в сторону и удаляется только после переноса; если перенос
+// не удался, он возвращается на место.
+func replace(src, target string) error {
+	if _, err := os.Lstat(target); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(src, target); err != nil {
+			return fmt.Errorf("failed to move model into place: %w", err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to move model into place: %w", err)
+	}
+
+	trash := filepath.Join(filepath.Dir(target), fmt.Sprintf("%s%d-%s", trashPrefix, os.Getpid(), filepath.Base(target)))
+	if err := os.Rename(target, trash); err != nil {
+		return fmt.Errorf("failed to move old model aside: %w", err)
+	}
+	if err := os.Rename(src, target); err != nil {
+		// возвращаем старую версию на место
+		_ = os.Rename(trash, target)
+		return fmt.Errorf("failed to move model into place: %w", err)
+	}
+	if err := fs.Remove(trash); err != nil {
+		return fmt.Errorf("failed to remove old model: %w", err)
+	}
+	return nil
+}
+
+// Cleanup удаляет каталоги распаковки и старые версии моделей, брошенные
+// упавшими или прерванными процессами.
+func (m *Manager) Cleanup() error {
+	entries, err := os.ReadDir(m.modelDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read model dir: %w", err)
+	}
+	for _, e := range entries {
+		rest, ok := strings.CutPrefix(e.Name(), stagingPrefix)
+		if !ok {
+			rest, ok = strings.CutPrefix(e.Name(), trashPrefix)
+		}
+		// ссылки остаются от прерванного import --link
+		if !ok || !e.IsDir() && e.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		pid, _, _ := strings.Cut(rest, "-")
+		if _pid, err := strconv.Atoi(pid); err == nil && alive(_pid) {
+			continue
+		}
+		if err := fs.Remove(filepath.Join(m.modelDir, e.Name())); err != nil {
+			return fmt.Errorf("failed to remove stale staging dir: %w", err)
+		}
+	}
+	return nil
+}
+
+func alive(pid int) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "model")
+	newDir := func(content string) string {
+		src, err := os.MkdirTemp(dir, stagingPrefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(src, "version"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return src
+	}
+	assertVersion := func(want string) {
+		t.Helper()
+		got, err := os.ReadFile(filepath.Join(target, "version"))
+		if err != nil || string(got) != want {
+			t.Fatalf("target has %q, %v, want %q", got, err, want)
+		}
+	}
+
+	if err := replace(newDir("v1"), target); err != nil {
+		t.Fatal(err)
+	}
+	assertVersion("v1")
+	if err := replace(newDir("v2"), target); err != nil {
+		t.Fatal(err)
+	}
+	assertVersion("v2")
+
+	// ссылка на месте модели тоже заменяется, а не разыменовывается
+	if err := os.RemoveAll(target); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "missing"), target); err != nil {
+		t.Fatal(err)
+	}
+	if err := replace(newDir("v3"), target); err != nil {
+		t.Fatal(err)
+	}
+	assertVersion("v3")
+
+	names, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range names {
+		if strings.HasPrefix(e.Name(), trashPrefix) || strings.HasPrefix(e.Name(), stagingPrefix) {
+			t.Fatalf("left behind %s", e.Name())
+		}
+	}
+}
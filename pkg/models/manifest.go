@@ -0,0 +1,213 @@
+package models
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"sluhach/pkg/fs"
+)
+
+// manifestName — файл внутри каталога модели, который пишется при установке.
+const manifestName = ".sluhach.json"
+
+var ErrNoManifest = errors.New("model has no manifest")
+
+// Manifest описывает установленную модель: откуда она взята и хеши всех
+// файлов на момент установки.
+type Manifest struct {
+	Name          string `json:"name"`
+	Source        string `json:"source"`
+	ArchiveSHA256 string `json:"archive_sha256"`
+	// Size, ETag и LastModified — сведения об архиве на сервере,
+	// по ним Outdated понимает, что модель обновилась.
+	Size         int64             `json:"size,omitempty"`
+	ETag         string            `json:"etag,omitempty"`
+	LastModified string            `json:"last_modified,omitempty"`
+	InstalledAt  time.Time         `json:"installed_at"`
+	Files        map[string]string `json:"files"`
+}
+
+// WithChecksums задаёт закреплённые SHA-256 архивов по имени модели.
+// Они важнее sidecar‑файлов *.sha256 из репозитория.
+func WithChecksums(checksums map[string]string) Option {
+	return func(m *Manager) {
+		m.checksums = checksums
+	}
+}
+
+// expectedChecksum ищет ожидаемый SHA-256 архива: сначала закреплённый
+// в конфиге, затем sidecar‑файл <url>.sha256 рядом с архивом в репозитории.
+// Пустая строка — sidecar‑файла нет и проверять не с чем. Если его не
+// удалось получить, это ошибка: иначе сбой сети молча отключал бы проверку.
+func (m *Manager) expectedChecksum(model, url string) (string, error) {
+	if sum, ok := m.checksums[model]; ok {
+		return strings.ToLower(sum), nil
+	}
+
+	body, _, err := m.open(url + ".sha256")
+	if errors.Is(err, ErrModelNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch checksum: %w", err)
+	}
+	defer body.Close()
+
+	// формат sha256sum: "<hex>  <file>"
+	data, err := io.ReadAll(io.LimitReader(body, 1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch checksum: %w: %w", ErrNetwork, err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 || len(fields[0]) != 64 {
+		return "", fmt.Errorf("%w: malformed %s.sha256", ErrChecksumMismatch, url)
+	}
+	if _, err := hex.DecodeString(fields[0]); err != nil {
+		return "", fmt.Errorf("%w: malformed %s.sha256", ErrChecksumMismatch, url)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// verifyArchive сверяет SHA-256 скачанного архива с ожидаемым.
+func (m *Manager) verifyArchive(model, url, path string) (string, error) {
+	expected, err := m.expectedChecksum(model, url)
+	if err != nil {
+		return "", err
+	}
+	sum, err := fs.SHA256(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash model archive: %w", err)
+	}
+	if expected != "" && expected != sum {
+		return "", fmt.Errorf("%w: archive %s has sha256 %s, expected %s", ErrChecksumMismatch, model, sum, expected)
+	}
+	return sum, nil
+}
+
+func hashTree(root string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// манифест и метка использования — не файлы модели
+		if d.IsDir() || path == filepath.Join(root, manifestName) || path == filepath.Join(root, lastUsedName) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		// у ссылки запоминаем цель: содержимое цели хешируется отдельно
+		if d.Type()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = "link:" + target
+			return nil
+		}
+		sum, err := fs.SHA256(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash model files: %w", err)
+	}
+	return files, nil
+}
+
+// writeManifest хеширует файлы установленной модели и сохраняет манифест.
+func writeManifest(dir string, manifest *Manifest) error {
+	files, err := hashTree(dir)
+	if err != nil {
+		return err
+	}
+	manifest.Files = files
+	manifest.InstalledAt = time.Now().UTC()
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestName), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+func readManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoManifest
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// Verify заново хеширует файлы установленной модели и сравнивает их
+// с манифестом, записанным при установке.
+func (m *Manager) Verify(model string) error {
+	installed, err := m.find(model)
+	if err != nil {
+		return err
+	}
+	if installed == nil {
+		return fmt.Errorf("%w: %s", ErrModelNotFound, model)
+	}
+	dir := installed.Path
+
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+	files, err := hashTree(dir)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, name := range sortedNames(manifest.Files) {
+		sum, ok := files[name]
+		switch {
+		case !ok:
+			problems = append(problems, "missing "+name)
+		case sum != manifest.Files[name]:
+			problems = append(problems, "modified "+name)
+		}
+	}
+	for _, name := range sortedNames(files) {
+		if _, ok := manifest.Files[name]; !ok {
+			problems = append(problems, "unexpected "+name)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+func sortedNames(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExpectedChecksum(t *testing.T) {
+	const sum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+		err    error
+	}{
+		{name: "sidecar", status: http.StatusOK, body: strings.ToUpper(sum) + "  model.zip\n", want: sum},
+		{name: "no sidecar", status: http.StatusNotFound},
+		// сбой сервера не должен молча отключать проверку
+		{name: "server error", status: http.StatusBadGateway, err: ErrNetwork},
+		{name: "short", status: http.StatusOK, body: "9f86d081  model.zip\n", err: ErrChecksumMismatch},
+		{name: "not hex", status: http.StatusOK, body: strings.Repeat("z", 64) + "  model.zip\n", err: ErrChecksumMismatch},
+		{name: "empty", status: http.StatusOK, err: ErrChecksumMismatch},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				io.WriteString(w, tc.body)
+			}))
+			defer srv.Close()
+
+			got, err := newTestManager(t).expectedChecksum("model", srv.URL+"/model.zip")
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("got %q, %v, want %v", got, err, tc.err)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Fatalf("got %q, %v, want %q", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpectedChecksumPinned(t *testing.T) {
+	// закреплённая сумма важнее sidecar и не требует сети
+	m := newTestManager(t, WithChecksums(map[string]string{"model": "ABC"}))
+	if got, err := m.expectedChecksum("model", "http://127.0.0.1:1/model.zip"); err != nil || got != "abc" {
+		t.Fatalf("got %q, %v, want pinned checksum", got, err)
+	}
+}
@@ -1,204 +1,317 @@
 package models
 
 import (
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"sluhach/pkg/fs"
-
-	"github.com/PuerkitoBio/goquery"
 )
 
-const (
-	_base = "https://alphacephei.com/vosk/models"
+var (
+	ErrModelNotFound    = errors.New("model not found")
+	ErrNetwork          = errors.New("network failure")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
 )
 
 type Manager struct {
-	base     string
-	modelDir string
-	client   *http.Client
+	repos       []Repo
+	modelDir    string
+	cacheDir    string
+	searchPaths []string
+	checksums   map[string]string
+	indexTTL    time.Duration
+	client      *http.Client
+	retries     int
+	userAgent   string
+	progress    Progress
+	// concurrency — сколько кусков архива качать одновременно.
+	concurrency int
+	// quota — предел общего размера моделей в modelDir, 0 — без него.
+	quota int64
 }
 
 type Model struct {
-	Lang, Name, Size, Desc string
-	Path                   string
+	// Lang — язык из каталога (English), LangCode — код из имени модели (en-us).
+	Lang      string `json:"lang,omitempty"`
+	LangCode  string `json:"lang_code,omitempty"`
+	Name      string `json:"name"`
+	Size      string `json:"size,omitempty"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+	WER       string `json:"wer,omitempty"`
+	Desc      string `json:"desc,omitempty"`
+	License   string `json:"license,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Path      string `json:"path,omitempty"`
+	// ReadOnly — модель найдена в общем каталоге, а не в modelDir.
+	ReadOnly bool `json:"read_only,omitempty"`
+
+	// Поля ниже заполняет List для установленных моделей.
+	SampleRate  int       `json:"sample_rate,omitempty"`
+	Rescore     []string  `json:"rescore,omitempty"`
+	InstalledAt time.Time `json:"installed_at,omitzero"`
+	// LastUsed — последняя загрузка для распознавания, иначе InstalledAt.
+	LastUsed time.Time `json:"last_used,omitzero"`
+	// Invalid — чего не хватает модели; пусто, если структура в порядке.
+	Invalid string `json:"invalid,omitempty"`
+}
+
+type Option func(*Manager)
+
+// WithSearchPaths добавляет каталоги (например, общесистемные), в которых
+// модели ищутся после modelDir. Модели из них не удаляются.
+func WithSearchPaths(paths ...string) Option {
+	return func(m *Manager) {
+		for _, p := range paths {
+			if p != m.modelDir {
+				m.searchPaths = append(m.searchPaths, p)
+			}
+		}
+	}
+}
+
+// WithCacheDir задаёт каталог для недокачанных архивов.
+func WithCacheDir(dir string) Option {
+	return func(m *Manager) {
+		m.cacheDir = dir
+	}
 }
 
 func New(
 	modelDir string,
+	opts ...Option,
 ) *Manager {
-	// стандартный http.Client по умолчанию использует системные настройки,
-	// включая переменные окружения HTTP_PROXY / HTTPS_PROXY / NO_PROXY.
-	client := &http.Client{}
+	// без CA‑файла и явного прокси ошибки быть не может; прокси по
+	// умолчанию берётся из HTTP_PROXY / HTTPS_PROXY / NO_PROXY
+	client, _ := NewClient(ClientOptions{})
 
-	return &Manager{
-		base:     _base,
+	m := &Manager{
+		repos:    []Repo{DefaultRepo},
 		modelDir: modelDir,
+		cacheDir: defaultCacheDir(),
+		indexTTL: defaultIndexTTL,
 		client:   client,
+		retries:  DefaultRetries,
+		progress: noProgress{},
+		// по умолчанию архив качается одним потоком
+		concurrency: 1,
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	// копия, чтобы не менять клиент, переданный в WithClient
+	_client := *m.client
+	_client.CheckRedirect = m.checkRedirect(_client.CheckRedirect)
+	m.client = &_client
+	return m
 }
 
-// Load скачивает zip‑архив модели по имени, распаковывает его в ModelDir
-// и удаляет временный файл.
-func (m *Manager) Load(model string) error {
-	// формируем URL архива
-	url := fmt.Sprintf("%s/%s.zip", strings.TrimRight(m.base, "/"), model)
-
-	tmp, err := os.CreateTemp(m.modelDir, model+"-*.zip")
+// find ищет установленную модель во всех каталогах; nil — не установлена.
+func (m *Manager) find(model string) (*Model, error) {
+	models, err := m.list()
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return nil, err
+	}
+	i := slices.IndexFunc(models, func(_m Model) bool { return _m.Name == model })
+	if i < 0 {
+		return nil, nil
 	}
+	return &models[i], nil
+}
 
-	path := tmp.Name()
-	defer func() {
-		tmp.Close()
-		_ = os.Remove(path)
-	}()
+// Installed сообщает, установлена ли модель в одном из каталогов.
+func (m *Manager) Installed(model string) (bool, error) {
+	installed, err := m.find(model)
+	return installed != nil, err
+}
 
-	// создаём запрос
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+// Load скачивает zip‑архив модели по имени, проверяет его SHA-256,
+// распаковывает во временный каталог, проверяет структуру модели и только
+// затем переносит её в ModelDir. Репозитории перебираются по порядку, пока
+// архив не найдётся; следующий пробуется, только если модели нет или
+// репозиторий недоступен. Оборванная загрузка остаётся в cacheDir и
+// продолжается при следующем вызове. Уже установленная модель
+// переустанавливается только при force.
+func (m *Manager) Load(model string, force bool) error {
+	installed, err := m.find(model)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
+	}
+	if installed != nil && !force {
+		return fmt.Errorf("%w: %s (%s)", ErrModelExists, model, installed.Path)
 	}
-	// стандартные заголовки для всех запросов
-	defaultHeaders(req)
 
-	resp, err := m.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to download model: %w", err)
+	var errs []error
+	for _, repo := range m.repos {
+		err := m.loadFrom(repo, model, force)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrModelNotFound) && !errors.Is(err, ErrNetwork) {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("repo %s: %w", repo.Name, err))
 	}
-	defer resp.Body.Close()
+	return errors.Join(errs...)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download model, status: %s", resp.Status)
-	}
-
-	if resp.ContentLength > 0 {
-		var downloaded int64
-		total := resp.ContentLength
-
-		buf := make([]byte, 32*1024)
-		for {
-			n, _err := resp.Body.Read(buf)
-			if n > 0 {
-				if _, err := tmp.Write(buf[:n]); err != nil {
-					return fmt.Errorf("failed to save model archive: %w", err)
-				}
-				downloaded += int64(n)
-				percent := float64(downloaded) * 100 / float64(total)
-				fmt.Printf("\r⬇️ downloaded: %.1f%% (%d / %d bytes)", percent, downloaded, total)
-			}
-			if _err == io.EOF {
-				break
-			}
-			if _err != nil {
-				return fmt.Errorf("failed to read response body: %w", _err)
-			}
+func (m *Manager) loadFrom(repo Repo, model string, force bool) error {
+	source := repo.archive(model)
+
+	var (
+		path string
+		meta *partial
+	)
+	if isLocal(source) {
+		// из локального репозитория архив читается на месте
+		info, err := os.Stat(source)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrModelNotFound, source)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read model archive: %w", err)
+		}
+		if err := m.checkSpace(model, info.Size(), 0); err != nil {
+			return err
+		}
+		path = source
+		meta = &partial{
+			URL:          source,
+			Size:         info.Size(),
+			LastModified: info.ModTime().UTC().Format(http.TimeFormat),
 		}
-		fmt.Println()
 	} else {
-		// если размер неизвестен — просто копируем без процентов
-		if _, err := io.Copy(tmp, resp.Body); err != nil {
-			return fmt.Errorf("failed to save model archive: %w", err)
+		path = m.partialPath(model + ".zip")
+		err := m.download(model, source, path)
+		// оборванная загрузка продолжается с места обрыва
+		for attempt := 0; errors.Is(err, errInterrupted) && attempt < m.retries; attempt++ {
+			time.Sleep(backoff(attempt))
+			err = m.download(model, source, path)
+		}
+		if err != nil {
+			return err
 		}
+		meta = readPartial(path)
 	}
 
-	if err := tmp.Close(); err != nil {
-		return fmt.Errorf("failed to close temp file: %w", err)
+	sum, err := m.verifyArchive(model, source, path)
+	if errors.Is(err, ErrNetwork) {
+		// не получен только sidecar с суммой, архив скачивать заново незачем
+		return err
 	}
-
-	if err := fs.Unzip(path, m.modelDir); err != nil {
-		return fmt.Errorf("failed to unzip model: %w", err)
+	if !isLocal(source) {
+		// архив либо распакован, либо битый — в обоих случаях докачивать нечего
+		defer removePartial(path)
 	}
-
-	return nil
-}
-
-func (m *Manager) Avail() ([]Model, error) {
-	// создаём запрос, чтобы можно было навесить те же заголовки
-	req, err := http.NewRequest(http.MethodGet, m.base, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
-	defaultHeaders(req)
 
-	resp, err := m.client.Do(req)
+	staging, err := m.stage(model)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch models page: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
+	defer fs.Remove(staging)
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if err := fs.Extract(path, staging); err != nil {
+		return fmt.Errorf("failed to extract model: %w", err)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse html: %w", err)
+	manifest := &Manifest{
+		Name:          model,
+		Source:        source,
+		ArchiveSHA256: sum,
 	}
-
-	table := doc.Find("table.table.table-bordered").First()
-	if table.Length() == 0 {
-		return nil, fmt.Errorf("models table not found")
+	if meta != nil {
+		manifest.Size = meta.Size
+		manifest.ETag = meta.ETag
+		manifest.LastModified = meta.LastModified
 	}
+	return m.install(staging, manifest, force)
+}
 
-	// получаем tbody из таблицы (если он есть)
-	tbody := table.Find("tbody")
-	if tbody.Length() == 0 {
-		return nil, fmt.Errorf("models content not found")
+// Outdated сравнивает размер, ETag и Last-Modified архива в источнике,
+// из которого модель ставилась, с записанными в манифесте при установке.
+// Модель без манифеста считается устаревшей: сравнить её не с чем.
+func (m *Manager) Outdated(model string) (bool, error) {
+	installed, err := m.find(model)
+	if err != nil {
+		return false, err
+	}
+	if installed == nil {
+		return false, fmt.Errorf("%w: %s", ErrModelNotFound, model)
+	}
+	manifest, err := readManifest(installed.Path)
+	if errors.Is(err, ErrNoManifest) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
 	}
 
-	var (
-		models []Model
-		lang   string
-	)
-
-	tbody.Find("tr").Each(func(i int, tr *goquery.Selection) {
-		var (
-			tds []*goquery.Selection
-		)
-		tr.Find("td").Each(func(i int, td *goquery.Selection) {
-			if strings.TrimSpace(td.Text()) == "" {
-				return
-			}
-			tds = append(tds, td)
-		})
-		if len(tds) < 5 {
-			if len(tds) > 0 {
-				lang = strings.TrimSpace(tds[0].Text())
-			}
-			return
+	if manifest.Source == "" {
+		return true, nil
+	}
+	if isLocal(manifest.Source) {
+		info, err := os.Stat(manifest.Source)
+		if errors.Is(err, os.ErrNotExist) {
+			return false, fmt.Errorf("%w: %s", ErrModelNotFound, manifest.Source)
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to check model: %w", err)
+		}
+		// модель импортирована из каталога: сравнивать не с чем
+		if info.IsDir() {
+			return false, nil
 		}
-		models = append(models,
-			Model{
-				Lang: strings.TrimSpace(lang),
-				Name: strings.TrimSpace(tds[0].Text()),
-				Size: strings.TrimSpace(tds[1].Text()),
-				Desc: strings.TrimSpace(tds[3].Text()),
-			},
-		)
-	})
+		return info.Size() != manifest.Size ||
+			info.ModTime().UTC().Format(http.TimeFormat) != manifest.LastModified, nil
+	}
 
-	if len(models) == 0 {
-		return nil, fmt.Errorf("not found any model")
+	req, err := m.newRequest(http.MethodHead, manifest.Source)
+	if err != nil {
+		return false, err
+	}
+	resp, err := m.do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to check model: %w: %w", ErrNetwork, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, fmt.Errorf("%w: %s", ErrModelNotFound, model)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to check model: %w: status %s", ErrNetwork, resp.Status)
 	}
 
-	return models, nil
+	changed := func(local, remote string) bool {
+		return local != "" && remote != "" && local != remote
+	}
+	switch {
+	case changed(manifest.ETag, resp.Header.Get("ETag")),
+		changed(manifest.LastModified, resp.Header.Get("Last-Modified")),
+		manifest.Size > 0 && resp.ContentLength > 0 && manifest.Size != resp.ContentLength:
+		return true, nil
+	}
+	return false, nil
 }
 
 func (m *Manager) Remove(model string) error {
-	models, err := fs.List(m.modelDir)
+	installed, err := m.find(model)
 	if err != nil {
-		return fmt.Errorf("failed to get models list: %w", err)
+		return err
+	}
+	if installed == nil {
+		return fmt.Errorf("%w: %s", ErrModelNotFound, model)
 	}
-	if !slices.Contains(models, model) {
-		return fmt.Errorf("model %s not exists", model)
+	if installed.ReadOnly {
+		return fmt.Errorf("model %s is installed in shared dir %s, remove it there", model, filepath.Dir(installed.Path))
 	}
 	if err := fs.Remove(path.Join(m.modelDir, model)); err != nil {
 		return fmt.Errorf("failed to remove: %w", err)
@@ -206,20 +319,53 @@ func (m *Manager) Remove(model string) error {
 	return nil
 }
 
+// List возвращает установленные модели со сведениями с диска.
 func (m *Manager) List() ([]Model, error) {
-	_models, err := fs.List(m.modelDir)
+	models, err := m.list()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get models list: %w", err)
+		return nil, err
 	}
-	if len(_models) == 0 {
+	if len(models) == 0 {
 		return nil, fmt.Errorf("no models loaded")
 	}
+	for i := range models {
+		inspect(&models[i])
+	}
+	return models, nil
+}
+
+// list возвращает модели из modelDir и каталогов поиска. Модель из modelDir
+// перекрывает одноимённую модель из общего каталога. Файлы (архивы и прочее,
+// что положили рядом руками) моделями не считаются.
+func (m *Manager) list() ([]Model, error) {
 	var models []Model
-	for _, name := range _models {
-		models = append(models, Model{
-			Name: name,
-			Path: filepath.Join(m.modelDir, name),
-		})
+	for i, dir := range append([]string{m.modelDir}, m.searchPaths...) {
+		_models, err := fs.List(dir)
+		if errors.Is(err, os.ErrNotExist) && i > 0 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get models list: %w", err)
+		}
+		for _, name := range _models {
+			// скрытые записи — служебные: каталоги распаковки и т. п.
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			if slices.ContainsFunc(models, func(_m Model) bool { return _m.Name == name }) {
+				continue
+			}
+			// Stat, а не Lstat: ссылка на каталог модели — тоже модель, а битая
+			// ссылка остаётся в списке, чтобы её было видно и можно было удалить
+			if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+				continue
+			}
+			models = append(models, Model{
+				Name:     name,
+				Path:     filepath.Join(dir, name),
+				ReadOnly: i > 0,
+			})
+		}
 	}
 	return models, nil
 }
@@ -0,0 +1,30 @@
+package models
+
+// Progress получает сведения о ходе загрузки архива. Пакет сам ничего не
+// печатает: как показать прогресс, решает вызывающий код.
+type Progress interface {
+	// Start вызывается перед загрузкой. offset — сколько байт уже было
+	// скачано прошлой попыткой, total — полный размер или -1, если он
+	// неизвестен.
+	Start(name string, offset, total int64)
+	// Update сообщает, сколько байт скачано всего, включая offset.
+	Update(downloaded int64)
+	// Finish вызывается один раз по окончании загрузки; err — её итог.
+	Finish(err error)
+}
+
+// WithProgress задаёт получателя хода загрузки.
+func WithProgress(progress Progress) Option {
+	return func(m *Manager) {
+		if progress != nil {
+			m.progress = progress
+		}
+	}
+}
+
+// noProgress — Progress по умолчанию, ничего не делает.
+type noProgress struct{}
+
+func (noProgress) Start(string, int64, int64) {}
+func (noProgress) Update(int64)               {}
+func (noProgress) Finish(error)               {}
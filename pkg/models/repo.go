@@ -0,0 +1,194 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultRepo — официальный каталог моделей Vosk. Используется, пока
+// в конфиге не задан ни один репозиторий.
+var DefaultRepo = Repo{
+	Name: "alphacephei",
+	URL:  "https://alphacephei.com/vosk/models",
+}
+
+// Repo — источник моделей: HTTP‑зеркало, локальный каталог или file:// URL.
+// В репозитории лежат архивы <model>.zip и, по желанию, <model>.zip.sha256.
+// Headers добавляются ко всем запросам в репозиторий, например Authorization.
+type Repo struct {
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// WithRepos задаёт репозитории, которые перебираются по порядку.
+// Пустой список оставляет DefaultRepo.
+func WithRepos(repos ...Repo) Option {
+	return func(m *Manager) {
+		if len(repos) > 0 {
+			m.repos = repos
+		}
+	}
+}
+
+// Repos возвращает репозитории в порядке перебора.
+func (m *Manager) Repos() []Repo {
+	return m.repos
+}
+
+// Validate проверяет имя и адрес репозитория.
+func (r Repo) Validate() error {
+	if r.Name == "" || strings.ContainsAny(r.Name, " \t/") {
+		return fmt.Errorf("invalid repo name %q", r.Name)
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return fmt.Errorf("invalid repo url %q: %w", r.URL, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return fmt.Errorf("invalid repo url %q: no host", r.URL)
+		}
+	case "file":
+		if u.Path == "" {
+			return fmt.Errorf("invalid repo url %q: no path", r.URL)
+		}
+	case "":
+		if !filepath.IsAbs(r.URL) {
+			return fmt.Errorf("repo dir %q must be an absolute path", r.URL)
+		}
+	default:
+		return fmt.Errorf("unsupported repo url scheme %q", u.Scheme)
+	}
+	for key := range r.Headers {
+		if key == "" || strings.ContainsAny(key, " :\t\r\n") {
+			return fmt.Errorf("invalid header name %q", key)
+		}
+	}
+	return nil
+}
+
+// dir возвращает каталог локального репозитория; false — репозиторий HTTP.
+func (r Repo) dir() (string, bool) {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return r.URL, true
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return "", false
+	case "file":
+		return u.Path, true
+	}
+	return r.URL, true
+}
+
+// archive возвращает URL или путь архива модели в репозитории.
+func (r Repo) archive(model string) string {
+	if dir, ok := r.dir(); ok {
+		return filepath.Join(dir, model+".zip")
+	}
+	return fmt.Sprintf("%s/%s.zip", strings.TrimRight(r.URL, "/"), model)
+}
+
+// contains сообщает, что ссылка ведёт внутрь репозитория: заголовки
+// авторизации не должны уходить на сторонние адреса.
+func (r Repo) contains(link string) bool {
+	base := strings.TrimRight(r.URL, "/")
+	return link == base || strings.HasPrefix(link, base+"/")
+}
+
+// isLocal сообщает, что источник — путь в файловой системе, а не HTTP URL.
+func isLocal(source string) bool {
+	return !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://")
+}
+
+// newRequest создаёт запрос со стандартными заголовками и заголовками
+// репозитория, которому принадлежит ссылка.
+func (m *Manager) newRequest(method, link string) (*http.Request, error) {
+	req, err := http.NewRequest(method, link, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if m.userAgent != "" {
+		req.Header.Set("User-Agent", m.userAgent)
+	} else {
+		defaultHeaders(req)
+	}
+	if repo, ok := m.repoOf(link); ok {
+		for key, value := range repo.Headers {
+			req.Header.Set(key, value)
+		}
+	}
+	return req, nil
+}
+
+// repoOf возвращает HTTP‑репозиторий, которому принадлежит ссылка.
+func (m *Manager) repoOf(link string) (Repo, bool) {
+	for _, repo := range m.repos {
+		if _, ok := repo.dir(); !ok && repo.contains(link) {
+			return repo, true
+		}
+	}
+	return Repo{}, false
+}
+
+// checkRedirect снимает заголовки репозитория, если редирект уводит за его
+// пределы: сам http.Client при смене хоста убирает только Authorization и
+// Cookie. next — проверка, которая была у клиента до этого.
+func (m *Manager) checkRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if repo, ok := m.repoOf(via[0].URL.String()); ok && !repo.contains(req.URL.String()) {
+			for key := range repo.Headers {
+				req.Header.Del(key)
+			}
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
+
+// open открывает файл из репозитория: локальный путь или HTTP URL.
+// Отсутствующий файл — ErrModelNotFound.
+func (m *Manager) open(source string) (io.ReadCloser, http.Header, error) {
+	if isLocal(source) {
+		f, err := os.Open(source)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrModelNotFound, source)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open %s: %w", source, err)
+		}
+		return f, nil, nil
+	}
+
+	req, err := m.newRequest(http.MethodGet, source)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := m.do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch %s: %w: %w", source, ErrNetwork, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, resp.Header, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("%w: %s", ErrModelNotFound, source)
+	}
+	resp.Body.Close()
+	return nil, nil, fmt.Errorf("failed to fetch %s: %w: status %s", source, ErrNetwork, resp.Status)
+}
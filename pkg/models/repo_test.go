@@ -0,0 +1,65 @@
+package models
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRepoHeadersStayInRepo(t *testing.T) {
+	// сторонний адрес, куда ведёт редирект из репозитория
+	var leaked []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = append(leaked, r.Header.Get("X-Api-Key"))
+		io.WriteString(w, "ok")
+	}))
+	defer other.Close()
+
+	var kept []string
+	repo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/models/moved.zip":
+			http.Redirect(w, r, "/models/model.zip", http.StatusFound)
+		case "/models/model.zip":
+			kept = append(kept, r.Header.Get("X-Api-Key"))
+			io.WriteString(w, "ok")
+		case "/models/cdn.zip":
+			http.Redirect(w, r, other.URL+"/model.zip", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer repo.Close()
+
+	m := newTestManager(t, WithRepos(Repo{
+		Name:    "nas",
+		URL:     repo.URL + "/models",
+		Headers: map[string]string{"X-Api-Key": "secret"},
+	}))
+	for _, name := range []string{"moved.zip", "cdn.zip"} {
+		body, _, err := m.open(repo.URL + "/models/" + name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		body.Close()
+	}
+
+	if len(kept) != 1 || kept[0] != "secret" {
+		t.Fatalf("redirect inside the repo lost headers: %q", kept)
+	}
+	if len(leaked) != 1 || leaked[0] != "" {
+		t.Fatalf("repo headers leaked to another host: %q", leaked)
+	}
+}
+
+func TestWithClientIsNotModified(t *testing.T) {
+	client := &http.Client{}
+	m := newTestManager(t, WithClient(client))
+	if client.CheckRedirect != nil {
+		t.Fatal("WithClient client was modified")
+	}
+	if m.client == client || m.client.CheckRedirect == nil {
+		t.Fatal("manager client has no redirect check")
+	}
+}
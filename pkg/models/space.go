@@ -0,0 +1,213 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"sluhach/pkg/fs"
+)
+
+// expansionFactor — во сколько раз распакованная модель бывает больше
+// архива, с запасом: у больших моделей с rnnlm выходит около 2.5.
+const expansionFactor = 3
+
+// lastUsedName — метка внутри каталога модели; время её изменения —
+// последняя загрузка модели для распознавания.
+const lastUsedName = ".last-used"
+
+var ErrNoSpace = errors.New("not enough disk space")
+
+// WithQuota ограничивает общий размер моделей в modelDir; 0 — без ограничения.
+func WithQuota(quota int64) Option {
+	return func(m *Manager) {
+		m.quota = quota
+	}
+}
+
+// MarkUsed отмечает, что модель из dir только что использовалась; по этой
+// отметке Prune выбирает, что удалять. Модели, подключённые ссылкой, и
+// каталоги только для чтения не трогаются, ошибки не важны.
+func MarkUsed(dir string) {
+	if info, err := os.Lstat(dir); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		return
+	}
+	path := filepath.Join(dir, lastUsedName)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); errors.Is(err, os.ErrNotExist) {
+		if f, err := os.Create(path); err == nil {
+			f.Close()
+		}
+	}
+}
+
+// lastUsed — время последнего использования модели; если модель ещё ни
+// разу не загружалась, то время установки.
+func lastUsed(model *Model) time.Time {
+	if info, err := os.Stat(filepath.Join(model.Path, lastUsedName)); err == nil {
+		return info.ModTime().UTC()
+	}
+	return model.InstalledAt
+}
+
+// checkSpace до загрузки проверяет, что архив размером archive поместится:
+// download байт ещё предстоит скачать в cacheDir, а распакованная модель
+// займёт до expansionFactor размеров архива в modelDir. Учитывается и квота;
+// модель model при переустановке из неё исключается. Неизвестный размер
+// (archive <= 0) не проверяется.
+func (m *Manager) checkSpace(model string, archive, download int64) error {
+	if archive <= 0 {
+		return nil
+	}
+	return m.checkUnpacked(model, archive*expansionFactor, download)
+}
+
+// checkUnpacked — checkSpace для модели, размер которой в распакованном
+// виде уже известен, например при импорте каталога.
+func (m *Manager) checkUnpacked(model string, unpacked, download int64) error {
+	if m.quota > 0 {
+		used, err := m.usage(model)
+		if err != nil {
+			return err
+		}
+		if used+unpacked > m.quota {
+			return fmt.Errorf(
+				"%w: quota %s exceeded: models use %s, %s needs up to %s (run \"sluhach model prune\")",
+				ErrNoSpace, FormatSize(m.quota), FormatSize(used), model, FormatSize(unpacked),
+			)
+		}
+	}
+
+	modelFree, modelDev, err := diskFree(existingParent(m.modelDir))
+	if err != nil {
+		// не смогли узнать — не мешаем установке
+		return nil
+	}
+	need := unpacked
+	if download > 0 {
+		cacheFree, cacheDev, err := diskFree(existingParent(m.cacheDir))
+		if err == nil && cacheDev != modelDev && download > cacheFree {
+			return fmt.Errorf("%w: %s needs %s in %s, %s free", ErrNoSpace, model, FormatSize(download), m.cacheDir, FormatSize(cacheFree))
+		}
+		if err == nil && cacheDev == modelDev {
+			need += download
+		}
+	}
+	if need > modelFree {
+		return fmt.Errorf("%w: %s needs up to %s in %s, %s free", ErrNoSpace, model, FormatSize(need), m.modelDir, FormatSize(modelFree))
+	}
+	return nil
+}
+
+// existingParent возвращает dir или ближайший существующий родитель:
+// каталог моделей может быть ещё не создан.
+func existingParent(dir string) string {
+	for {
+		if err := fs.Exists(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// owned возвращает модели, которые занимают место в modelDir: без моделей
+// из общих каталогов и без подключённых ссылкой.
+func (m *Manager) owned() ([]Model, error) {
+	models, err := m.list()
+	if err != nil {
+		return nil, err
+	}
+	var owned []Model
+	for _, model := range models {
+		if model.ReadOnly {
+			continue
+		}
+		if info, err := os.Lstat(model.Path); err != nil || !info.IsDir() {
+			continue
+		}
+		inspect(&model)
+		owned = append(owned, model)
+	}
+	return owned, nil
+}
+
+// usage — общий размер моделей в modelDir без модели except.
+func (m *Manager) usage(except string) (int64, error) {
+	owned, err := m.owned()
+	if err != nil {
+		return 0, err
+	}
+	var used int64
+	for _, model := range owned {
+		if model.Name != except {
+			used += model.SizeBytes
+		}
+	}
+	return used, nil
+}
+
+// Usage возвращает общий размер моделей в modelDir и квоту (0 — без неё).
+func (m *Manager) Usage() (used, quota int64, err error) {
+	used, err = m.usage("")
+	return used, m.quota, err
+}
+
+// PruneOptions — параметры Prune.
+type PruneOptions struct {
+	// Limit — до какого размера ужать modelDir; 0 — до квоты из WithQuota.
+	Limit int64
+	// Keep — модели, которые не удаляются никогда (например, по умолчанию).
+	Keep []string
+	// DryRun — только выбрать модели, ничего не удаляя.
+	DryRun bool
+}
+
+// Prune удаляет из modelDir дольше всех не использованные модели, пока их
+// общий размер не уложится в предел. Возвращает удалённые модели в порядке
+// удаления.
+func (m *Manager) Prune(opts PruneOptions) ([]Model, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = m.quota
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("no quota set, pass a size limit")
+	}
+
+	owned, err := m.owned()
+	if err != nil {
+		return nil, err
+	}
+	var used int64
+	for _, model := range owned {
+		used += model.SizeBytes
+	}
+	slices.SortStableFunc(owned, func(a, b Model) int {
+		return a.LastUsed.Compare(b.LastUsed)
+	})
+
+	var pruned []Model
+	for _, model := range owned {
+		if used <= limit {
+			break
+		}
+		if slices.Contains(opts.Keep, model.Name) {
+			continue
+		}
+		if !opts.DryRun {
+			if err := fs.Remove(model.Path); err != nil {
+				return pruned, fmt.Errorf("failed to remove %s: %w", model.Name, err)
+			}
+		}
+		used -= model.SizeBytes
+		pruned = append(pruned, model)
+	}
+	return pruned, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package models
+
+import "errors"
+
+// diskFree не реализован: свободное место не проверяется.
+func diskFree(dir string) (int64, uint64, error) {
+	return 0, 0, errors.ErrUnsupported
+}
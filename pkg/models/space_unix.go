@@ -0,0 +1,21 @@
+//go:build unix
+
+package models
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// diskFree возвращает место, доступное непривилегированному пользователю
+// на файловой системе dir, и её идентификатор для сравнения.
+func diskFree(dir string) (int64, uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	var stat unix.Stat_t
+	if err := unix.Stat(dir, &stat); err != nil {
+		return 0, 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), uint64(stat.Dev), nil
+}
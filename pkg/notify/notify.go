@@ -1,6 +1,7 @@
 package notify
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/gen2brain/beeep"
@@ -8,13 +9,15 @@ import (
 
 const appName = "Sluhach"
 
+var ErrUnavailable = errors.New("notifications unavailable")
+
 func Notify(title, s string, icon ...string) error {
 	if len(icon) == 0 {
 		icon = append(icon, "media-record-symbolic")
 	}
 	beeep.AppName = appName
 	if err := beeep.Notify(title, s, icon[0]); err != nil {
-		return fmt.Errorf("failed to send notification: %w", err)
+		return fmt.Errorf("%w: failed to send notification: %w", ErrUnavailable, err)
 	}
 	return nil
 }
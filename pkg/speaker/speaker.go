@@ -0,0 +1,196 @@
+package speaker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"sluhach/pkg/fs"
+)
+
+// FileName — файл с записанными говорящими в каталоге моделей.
+const FileName = ".speakers.json"
+
+// DefaultMaxDistance — косинусное расстояние, дальше которого говорящий
+// считается неизвестным. У одного человека в разных записях оно обычно
+// 0.3–0.5, у разных людей — от 0.7.
+const DefaultMaxDistance = 0.55
+
+var (
+	ErrNotFound  = errors.New("speaker not found")
+	ErrNoSamples = errors.New("no speech long enough to enroll a speaker")
+)
+
+// Embedding — x-vector фразы и число кадров, по которым он посчитан:
+// чем их больше, тем вектор надёжнее.
+type Embedding struct {
+	Vector []float64
+	Frames int
+}
+
+// Speaker — записанный говорящий: средний x-vector по всем образцам.
+type Speaker struct {
+	Name string `json:"name"`
+	// Model — модель спикеров, которой посчитан вектор; векторы разных
+	// моделей не сравнимы.
+	Model  string    `json:"model"`
+	Vector []float64 `json:"vector"`
+	Frames int       `json:"frames"`
+	// Samples — сколько фраз вошло в вектор.
+	Samples    int       `json:"samples"`
+	EnrolledAt time.Time `json:"enrolled_at"`
+}
+
+type file struct {
+	Speakers []Speaker `json:"speakers"`
+}
+
+// Store хранит говорящих в JSON‑файле.
+type Store struct {
+	path string
+}
+
+func New(path string) *Store {
+	return &Store{path: path}
+}
+
+// List возвращает говорящих по имени.
+func (s *Store) List() ([]Speaker, error) {
+	f, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return f.Speakers, nil
+}
+
+// Enroll добавляет образцы голоса. Если говорящий уже записан той же
+// моделью, новые образцы усредняются с прежними, иначе он записывается
+// заново.
+func (s *Store) Enroll(name, model string, samples []Embedding) (Speaker, error) {
+	samples = slices.DeleteFunc(samples, func(e Embedding) bool {
+		return len(e.Vector) == 0 || e.Frames <= 0
+	})
+	if len(samples) == 0 {
+		return Speaker{}, ErrNoSamples
+	}
+
+	f, err := s.read()
+	if err != nil {
+		return Speaker{}, err
+	}
+	i := slices.IndexFunc(f.Speakers, func(sp Speaker) bool { return sp.Name == name })
+	if i < 0 || f.Speakers[i].Model != model {
+		sp := Speaker{Name: name, Model: model}
+		if i < 0 {
+			f.Speakers = append(f.Speakers, sp)
+			i = len(f.Speakers) - 1
+		} else {
+			f.Speakers[i] = sp
+		}
+	}
+
+	sp := &f.Speakers[i]
+	for _, e := range samples {
+		if sp.Vector == nil {
+			sp.Vector = make([]float64, len(e.Vector))
+		}
+		if len(e.Vector) != len(sp.Vector) {
+			return Speaker{}, fmt.Errorf("speaker vector has %d values, expected %d", len(e.Vector), len(sp.Vector))
+		}
+		// среднее, взвешенное по числу кадров
+		total := float64(sp.Frames + e.Frames)
+		for j := range sp.Vector {
+			sp.Vector[j] = (sp.Vector[j]*float64(sp.Frames) + e.Vector[j]*float64(e.Frames)) / total
+		}
+		sp.Frames += e.Frames
+		sp.Samples++
+	}
+	sp.EnrolledAt = time.Now().UTC()
+	result := *sp
+
+	slices.SortFunc(f.Speakers, func(a, b Speaker) int { return strings.Compare(a.Name, b.Name) })
+	if err := s.write(f); err != nil {
+		return Speaker{}, err
+	}
+	return result, nil
+}
+
+// Remove удаляет говорящего.
+func (s *Store) Remove(name string) error {
+	f, err := s.read()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(f.Speakers, func(sp Speaker) bool { return sp.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	f.Speakers = slices.Delete(f.Speakers, i, i+1)
+	return s.write(f)
+}
+
+// Match ищет среди speakers, записанных моделью model, ближайшего к vector.
+// Пустое имя — никто не ближе maxDistance.
+func Match(speakers []Speaker, model string, vector []float64, maxDistance float64) (string, float64) {
+	var (
+		name string
+		best = math.Inf(1)
+	)
+	for _, sp := range speakers {
+		if sp.Model != model || len(sp.Vector) != len(vector) {
+			continue
+		}
+		if d := Distance(sp.Vector, vector); d < best {
+			name, best = sp.Name, d
+		}
+	}
+	if best > maxDistance {
+		return "", best
+	}
+	return name, best
+}
+
+// Distance — косинусное расстояние: 0 — векторы сонаправлены, 2 — противоположны.
+func Distance(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 1
+	}
+	return 1 - dot/(math.Sqrt(na)*math.Sqrt(nb))
+}
+
+func (s *Store) read() (*file, error) {
+	var f file
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read speakers: %w", err)
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse speakers %s: %w", s.path, err)
+	}
+	return &f, nil
+}
+
+func (s *Store) write(f *file) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode speakers: %w", err)
+	}
+	if err := fs.WriteFileAtomic(s.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save speakers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"sluhach/pkg/fs"
+)
+
+// FileName — файл статистики в каталоге моделей.
+const FileName = ".stats.json"
+
+// Model — накопленная статистика одной модели. Когда модель последний раз
+// использовалась, здесь не хранится: это models.Model.LastUsed.
+type Model struct {
+	Name string `json:"name"`
+	// Loads и LoadSeconds — сколько раз модель загружалась и сколько всего
+	// это заняло.
+	Loads       int     `json:"loads"`
+	LoadSeconds float64 `json:"load_seconds"`
+	// Runs — запуски распознавания; AudioSeconds — сколько звука
+	// распознано, ProcessingSeconds — сколько на это ушло у Vosk.
+	Runs              int     `json:"runs"`
+	AudioSeconds      float64 `json:"audio_seconds"`
+	ProcessingSeconds float64 `json:"processing_seconds"`
+}
+
+// AvgLoad — средняя длительность загрузки модели.
+func (m *Model) AvgLoad() time.Duration {
+	if m.Loads == 0 {
+		return 0
+	}
+	return time.Duration(m.LoadSeconds / float64(m.Loads) * float64(time.Second))
+}
+
+// RTF — real-time factor: доля реального времени, которая уходит на
+// распознавание. Меньше 1 — модель успевает за речью.
+func (m *Model) RTF() float64 {
+	if m.AudioSeconds == 0 {
+		return 0
+	}
+	return m.ProcessingSeconds / m.AudioSeconds
+}
+
+type file struct {
+	Models map[string]*Model `json:"models"`
+}
+
+// Store хранит статистику в JSON‑файле. Каждая запись перечитывает файл,
+// так что параллельные запуски sluhach теряют в худшем случае одну запись.
+type Store struct {
+	path string
+	// Err — первая ошибка записи; распознаванию она не мешает, поэтому
+	// вызывающий код только сообщает о ней.
+	Err error
+}
+
+func New(path string) *Store {
+	return &Store{path: path}
+}
+
+// Loaded учитывает загрузку модели.
+func (s *Store) Loaded(model string, took time.Duration) {
+	s.fail(s.update(model, func(m *Model) {
+		m.Loads++
+		m.LoadSeconds += took.Seconds()
+	}))
+}
+
+// Recognized учитывает запуск распознавания: сколько звука и за какое время.
+func (s *Store) Recognized(model string, audio, took time.Duration) {
+	s.fail(s.update(model, func(m *Model) {
+		m.Runs++
+		m.AudioSeconds += audio.Seconds()
+		m.ProcessingSeconds += took.Seconds()
+	}))
+}
+
+// fail запоминает ошибку записи, если раньше её не было: следующая удачная
+// запись не должна её скрыть.
+func (s *Store) fail(err error) {
+	if s.Err == nil {
+		s.Err = err
+	}
+}
+
+// All возвращает статистику всех моделей по имени.
+func (s *Store) All() ([]Model, error) {
+	f, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	models := make([]Model, 0, len(f.Models))
+	for _, m := range f.Models {
+		models = append(models, *m)
+	}
+	slices.SortFunc(models, func(a, b Model) int { return strings.Compare(a.Name, b.Name) })
+	return models, nil
+}
+
+// Reset удаляет статистику модели, а без имени — всю.
+func (s *Store) Reset(model string) error {
+	if model == "" {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to reset stats: %w", err)
+		}
+		return nil
+	}
+	f, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := f.Models[model]; !ok {
+		return fmt.Errorf("no stats for model %s", model)
+	}
+	delete(f.Models, model)
+	return s.write(f)
+}
+
+func (s *Store) update(model string, fn func(*Model)) error {
+	f, err := s.read()
+	if err != nil {
+		return err
+	}
+	m, ok := f.Models[model]
+	if !ok {
+		m = &Model{Name: model}
+		f.Models[model] = m
+	}
+	fn(m)
+	return s.write(f)
+}
+
+func (s *Store) read() (*file, error) {
+	f := &file{Models: make(map[string]*Model)}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stats: %w", err)
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("failed to parse stats %s: %w", s.path, err)
+	}
+	if f.Models == nil {
+		f.Models = make(map[string]*Model)
+	}
+	return f, nil
+}
+
+func (s *Store) write(f *file) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode stats: %w", err)
+	}
+	if err := fs.WriteFileAtomic(s.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save stats: %w", err)
+	}
+	return nil
+}
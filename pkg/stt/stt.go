@@ -3,49 +3,163 @@ package stt
 import (
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"sluhach/pkg/fs"
+	"sluhach/pkg/models"
 
 	vosk "github.com/alphacep/vosk-api/go"
 	"github.com/gordonklaus/portaudio"
 )
 
+var (
+	ErrModelNotFound     = errors.New("source vosk model not found")
+	ErrDeviceUnavailable = errors.New("audio device unavailable")
+	ErrNoSpeech          = errors.New("no speech detected")
+)
+
 type Speach2Text struct {
-	modelDir string
+	modelDir    string
+	searchPaths []string
+	aliases     map[string]string
+	recorder    Recorder
+
+	// loaded — имена загруженных моделей, чтобы Recognize знал, чью
+	// статистику пополнять
+	mu     sync.Mutex
+	loaded map[*vosk.VoskModel]string
+}
+
+// Recorder получает замеры загрузки моделей и распознавания. Ошибки записи
+// остаются на стороне Recorder: распознаванию они мешать не должны.
+type Recorder interface {
+	// Loaded — модель загружена за took.
+	Loaded(model string, took time.Duration)
+	// Recognized — распознано audio звука, Vosk потратил на это took.
+	Recognized(model string, audio, took time.Duration)
+}
+
+type noRecorder struct{}
+
+func (noRecorder) Loaded(string, time.Duration)                    {}
+func (noRecorder) Recognized(string, time.Duration, time.Duration) {}
+
+type Option func(*Speach2Text)
+
+// WithSearchPaths добавляет каталоги, в которых модели ищутся после modelDir.
+func WithSearchPaths(paths ...string) Option {
+	return func(s *Speach2Text) {
+		for _, p := range paths {
+			if p != s.modelDir {
+				s.searchPaths = append(s.searchPaths, p)
+			}
+		}
+	}
+}
+
+// WithAliases задаёт короткие имена моделей, которые понимает LoadModel.
+func WithAliases(aliases map[string]string) Option {
+	return func(s *Speach2Text) {
+		s.aliases = aliases
+	}
+}
+
+// WithRecorder задаёт, куда сообщать замеры, например в stats.Store.
+func WithRecorder(r Recorder) Option {
+	return func(s *Speach2Text) {
+		if r != nil {
+			s.recorder = r
+		}
+	}
+}
+
+// WithVerbose включает журнал vosk.
+func WithVerbose(verbose bool) Option {
+	return func(s *Speach2Text) {
+		if verbose {
+			vosk.SetLogLevel(0)
+		}
+	}
 }
 
 // sudo apt-get install portaudio19-dev
 // sudo dnf install portaudio-devel
 func New(
 	_modelDir string,
+	opts ...Option,
 ) *Speach2Text {
 	vosk.SetLogLevel(-1)
 
-	return &Speach2Text{
+	s := &Speach2Text{
 		modelDir: _modelDir,
+		recorder: noRecorder{},
+		loaded:   make(map[*vosk.VoskModel]string),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *Speach2Text) LoadModel(path string) (*vosk.VoskModel, error) {
-	path = filepath.Join(s.modelDir, path)
+// findModel ищет модель по имени в modelDir и каталогах поиска;
+// абсолютный путь используется как есть.
+func (s *Speach2Text) findModel(name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return name, fs.Exists(name)
+	}
+	var err error
+	for _, dir := range append([]string{s.modelDir}, s.searchPaths...) {
+		path := filepath.Join(dir, name)
+		if err = fs.Exists(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", err
+}
 
-	if err := fs.Exists(path); err != nil {
-		return nil, fmt.Errorf("source vosk model not found: %w", err)
+// Resolve переводит алиас в имя модели, остальные имена возвращает как есть.
+// Алиас важнее одноимённого каталога модели.
+func (s *Speach2Text) Resolve(name string) string {
+	if model, ok := s.aliases[name]; ok {
+		return model
+	}
+	return name
+}
+
+// LoadModel загружает модель по имени, алиасу или абсолютному пути.
+func (s *Speach2Text) LoadModel(name string) (*vosk.VoskModel, error) {
+	name = s.Resolve(name)
+	path, err := s.findModel(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrModelNotFound, err)
 	}
 
+	started := time.Now()
 	model, err := vosk.NewModel(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load vosk model: %w", err)
 	}
+	s.recorder.Loaded(name, time.Since(started))
+	s.mu.Lock()
+	s.loaded[model] = name
+	s.mu.Unlock()
+	// по этой отметке model prune решает, какие модели давно не нужны;
+	// каталог, заданный абсолютным путём, не наш — его не трогаем
+	if !filepath.IsAbs(name) {
+		models.MarkUsed(path)
+	}
 
 	return model, nil
 }
 
-func (s *Speach2Text) Recognize(model *vosk.VoskModel, wait int) (string, error) {
+// Recognize слушает микрофон до wait секунд тишины. Пустой device означает
+// устройство ввода по умолчанию.
+func (s *Speach2Text) Recognize(model *vosk.VoskModel, wait int, device string) (string, error) {
 	rec, err := vosk.NewRecognizer(model, 16000.0)
 	if err != nil {
 		return "", fmt.Errorf("failed to create recognizer: %w", err)
@@ -53,7 +167,7 @@ func (s *Speach2Text) Recognize(model *vosk.VoskModel, wait int) (string, error)
 	defer rec.Free()
 
 	if err := portaudio.Initialize(); err != nil {
-		return "", fmt.Errorf("failed to initilaze portaudio: %w", err)
+		return "", fmt.Errorf("%w: failed to initilaze portaudio: %w", ErrDeviceUnavailable, err)
 	}
 	defer portaudio.Terminate()
 
@@ -61,15 +175,35 @@ func (s *Speach2Text) Recognize(model *vosk.VoskModel, wait int) (string, error)
 		_err      error
 		collected []string
 		lastTime  = time.Now()
+		// samples и busy — сколько звука отдано Vosk и сколько он его
+		// обрабатывал; из них считается real-time factor
+		samples int64
+		busy    time.Duration
 	)
 
-	stream, err := portaudio.OpenDefaultStream(1, 0, 16000, 8000, func(in []int16) {
+	params, err := streamParameters(device)
+	if err != nil {
+		return "", err
+	}
+
+	// замер пишется после остановки потока, когда колбэк уже не вызывается
+	defer func() {
+		if samples > 0 {
+			s.recorder.Recognized(s.modelName(model), time.Duration(samples)*time.Second/time.Duration(params.SampleRate), busy)
+		}
+	}()
+
+	stream, err := portaudio.OpenStream(params, func(in []int16) {
 		if _err != nil {
 			return
 		}
 
 		data := int16ToBytes(in)
-		if b := rec.AcceptWaveform(data); b > 0 {
+		started := time.Now()
+		b := rec.AcceptWaveform(data)
+		busy += time.Since(started)
+		samples += int64(len(in))
+		if b > 0 {
 			var result map[string]interface{}
 			if err := json.Unmarshal([]byte(rec.Result()), &result); err != nil {
 				_err = fmt.Errorf("failed to unmarshal result: %w", err)
@@ -100,12 +234,12 @@ func (s *Speach2Text) Recognize(model *vosk.VoskModel, wait int) (string, error)
 		}
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to open audio channel: %w", err)
+		return "", fmt.Errorf("%w: failed to open audio channel: %w", ErrDeviceUnavailable, err)
 	}
 	defer stream.Close()
 
 	if err := stream.Start(); err != nil {
-		return "", fmt.Errorf("failed to listen: %w", err)
+		return "", fmt.Errorf("%w: failed to listen: %w", ErrDeviceUnavailable, err)
 	}
 	defer stream.Stop()
 
@@ -122,6 +256,9 @@ func (s *Speach2Text) Recognize(model *vosk.VoskModel, wait int) (string, error)
 	if _err != nil {
 		return "", _err
 	}
+	if len(collected) == 0 {
+		return "", ErrNoSpeech
+	}
 
 	return strings.Join(collected, "\n"), nil
 }
@@ -129,6 +266,7 @@ func (s *Speach2Text) Recognize(model *vosk.VoskModel, wait int) (string, error)
 func (s *Speach2Text) Start(
 	path string,
 	wait int,
+	device string,
 ) (string, error) {
 	model, err := s.LoadModel(path)
 	if err != nil {
@@ -136,7 +274,60 @@ func (s *Speach2Text) Start(
 	}
 	defer model.Free()
 
-	return s.Recognize(model, wait)
+	return s.Recognize(model, wait, device)
+}
+
+// modelName — имя, под которым модель загружена LoadModel.
+func (s *Speach2Text) modelName(model *vosk.VoskModel) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if name, ok := s.loaded[model]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// streamParameters ищет устройство ввода по точному имени, затем по подстроке.
+// portaudio должен быть уже инициализирован.
+func streamParameters(device string) (portaudio.StreamParameters, error) {
+	var (
+		dev *portaudio.DeviceInfo
+		err error
+	)
+	if device == "" {
+		dev, err = portaudio.DefaultInputDevice()
+		if err != nil {
+			return portaudio.StreamParameters{}, fmt.Errorf("%w: failed to get default input device: %w", ErrDeviceUnavailable, err)
+		}
+	} else {
+		devices, err := portaudio.Devices()
+		if err != nil {
+			return portaudio.StreamParameters{}, fmt.Errorf("%w: failed to list audio devices: %w", ErrDeviceUnavailable, err)
+		}
+		for _, d := range devices {
+			if d.MaxInputChannels > 0 && d.Name == device {
+				dev = d
+				break
+			}
+		}
+		if dev == nil {
+			for _, d := range devices {
+				if d.MaxInputChannels > 0 && strings.Contains(strings.ToLower(d.Name), strings.ToLower(device)) {
+					dev = d
+					break
+				}
+			}
+		}
+		if dev == nil {
+			return portaudio.StreamParameters{}, fmt.Errorf("%w: input device %q not found", ErrDeviceUnavailable, device)
+		}
+	}
+
+	params := portaudio.LowLatencyParameters(dev, nil)
+	params.Input.Channels = 1
+	params.SampleRate = 16000
+	params.FramesPerBuffer = 8000
+	return params, nil
 }
 
 func int16ToBytes(input []int16) []byte {
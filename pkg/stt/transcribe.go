@@ -0,0 +1,234 @@
+package stt
+
+import (
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"sluhach/pkg/models"
+
+	vosk "github.com/alphacep/vosk-api/go"
+)
+
+// ErrNoChannel — в файле нет запрошенного канала.
+var ErrNoChannel = errors.New("no such audio channel")
+
+// Segment — фраза из файла с границами в секундах от начала записи.
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// Channel — номер канала с 1; 0 — моно или сведённые каналы.
+	Channel int    `json:"channel,omitempty"`
+	Text    string `json:"text"`
+	// Speaker — имя опознанного говорящего, заполняет вызывающий код.
+	Speaker string `json:"speaker,omitempty"`
+	// Vector — x-vector говорящего, если подключена модель спикеров;
+	// Frames — по скольким кадрам он посчитан.
+	Vector []float64 `json:"spk,omitempty"`
+	Frames int       `json:"spk_frames,omitempty"`
+}
+
+// result — итог фразы от Vosk с включёнными SetWords.
+type result struct {
+	Text   string `json:"text"`
+	Result []struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+	} `json:"result"`
+	Spk       []float64 `json:"spk"`
+	SpkFrames int       `json:"spk_frames"`
+}
+
+// LoadSpkModel загружает модель спикеров (vosk-model-spk) по имени, алиасу
+// или абсолютному пути.
+func (s *Speach2Text) LoadSpkModel(name string) (*vosk.VoskSpkModel, error) {
+	name = s.Resolve(name)
+	path, err := s.findModel(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrModelNotFound, err)
+	}
+	model, err := vosk.NewSpkModel(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vosk speaker model: %w", err)
+	}
+	if !filepath.IsAbs(name) {
+		models.MarkUsed(path)
+	}
+	return model, nil
+}
+
+// FileOptions — как распознавать файл.
+type FileOptions struct {
+	// Speakers — модель спикеров: с ней у фраз есть x-vector говорящего.
+	Speakers *vosk.VoskSpkModel
+	// Channels — номера каналов с 1, каждый распознаётся отдельно; пусто —
+	// все каналы. В записи разговора на каждом канале обычно свой собеседник.
+	Channels []int
+	// Mix сводит все каналы в один перед распознаванием.
+	Mix bool
+}
+
+// stream — распознаватель одного канала (или сведённых каналов).
+type stream struct {
+	channel  int
+	rec      *vosk.VoskRecognizer
+	buf      []byte
+	segments []Segment
+}
+
+// Transcribe распознаёт WAV‑файл (16‑битный PCM) и возвращает фразы с
+// временем. У многоканальной записи каждый канал распознаётся своим
+// распознавателем, а фразы всех каналов сливаются в одну ленту по времени.
+func (s *Speach2Text) Transcribe(model *vosk.VoskModel, path string, opts FileOptions) ([]Segment, error) {
+	w, err := openWAV(path)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	channels, err := selectChannels(path, w.channels, opts)
+	if err != nil {
+		return nil, err
+	}
+	streams := make([]*stream, len(channels))
+	for i, channel := range channels {
+		rec, err := vosk.NewRecognizer(model, float64(w.sampleRate))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create recognizer: %w", err)
+		}
+		defer rec.Free()
+		rec.SetWords(1)
+		if opts.Speakers != nil {
+			rec.SetSpkModel(opts.Speakers)
+		}
+		streams[i] = &stream{channel: channel, rec: rec}
+	}
+
+	var (
+		busy time.Duration
+		// по 0.25 с звука за раз
+		frames = max(w.sampleRate/4, 1)
+		buf    = make([]byte, frames*w.channels*2)
+	)
+	for {
+		n, _err := io.ReadFull(w.data, buf)
+		// неполный последний кадр отбрасываем
+		if n -= n % (w.channels * 2); n > 0 {
+			for _, st := range streams {
+				st.buf = extract(st.buf[:0], buf[:n], w.channels, st.channel)
+				started := time.Now()
+				final := st.rec.AcceptWaveform(st.buf) > 0
+				busy += time.Since(started)
+				if final {
+					if st.segments, err = appendResult(st.segments, st.channel, st.rec.Result()); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		if errors.Is(_err, io.EOF) || errors.Is(_err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if _err != nil {
+			return nil, fmt.Errorf("failed to read audio file: %w", _err)
+		}
+	}
+
+	var segments []Segment
+	for _, st := range streams {
+		started := time.Now()
+		final := st.rec.FinalResult()
+		busy += time.Since(started)
+		if st.segments, err = appendResult(st.segments, st.channel, final); err != nil {
+			return nil, err
+		}
+		segments = append(segments, st.segments...)
+	}
+	// фразы каналов вперемешку, как шёл разговор
+	slices.SortStableFunc(segments, func(a, b Segment) int {
+		if a.Start != b.Start {
+			return cmp.Compare(a.Start, b.Start)
+		}
+		return cmp.Compare(a.Channel, b.Channel)
+	})
+
+	// каждый канал — отдельный звук для распознавателя
+	audio := time.Duration(w.frames) * time.Second / time.Duration(w.sampleRate) * time.Duration(len(streams))
+	if audio > 0 {
+		s.recorder.Recognized(s.modelName(model), audio, busy)
+	}
+	if len(segments) == 0 {
+		return nil, ErrNoSpeech
+	}
+	return segments, nil
+}
+
+// selectChannels возвращает номера распознаваемых каналов; 0 — моно или
+// сведённые каналы.
+func selectChannels(path string, total int, opts FileOptions) ([]int, error) {
+	if opts.Mix || total == 1 && len(opts.Channels) == 0 {
+		return []int{0}, nil
+	}
+	if len(opts.Channels) == 0 {
+		channels := make([]int, total)
+		for i := range channels {
+			channels[i] = i + 1
+		}
+		return channels, nil
+	}
+	var channels []int
+	for _, channel := range opts.Channels {
+		if channel < 1 || channel > total {
+			return nil, fmt.Errorf("%w %d, %s has %d", ErrNoChannel, channel, path, total)
+		}
+		if !slices.Contains(channels, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	slices.Sort(channels)
+	return channels, nil
+}
+
+// extract выбирает из перемежающихся кадров сэмплы канала channel (с 1);
+// channel 0 — среднее всех каналов.
+func extract(dst, data []byte, channels, channel int) []byte {
+	for frame := 0; frame+channels*2 <= len(data); frame += channels * 2 {
+		var sample int16
+		if channel > 0 {
+			sample = int16(binary.LittleEndian.Uint16(data[frame+(channel-1)*2:]))
+		} else {
+			var sum int
+			for c := range channels {
+				sum += int(int16(binary.LittleEndian.Uint16(data[frame+c*2:])))
+			}
+			sample = int16(sum / channels)
+		}
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(sample))
+	}
+	return dst
+}
+
+// appendResult добавляет непустую фразу из JSON‑результата Vosk.
+func appendResult(segments []Segment, channel int, data string) ([]Segment, error) {
+	var r result
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+	if r.Text == "" || len(r.Result) == 0 {
+		return segments, nil
+	}
+	return append(segments, Segment{
+		Start:   r.Result[0].Start,
+		End:     r.Result[len(r.Result)-1].End,
+		Channel: channel,
+		Text:    r.Text,
+		Vector:  r.Spk,
+		Frames:  r.SpkFrames,
+	}), nil
+}
@@ -0,0 +1,111 @@
+package stt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrUnsupportedAudio — файл не является WAV с 16‑битным PCM.
+var ErrUnsupportedAudio = errors.New("unsupported audio file")
+
+// wavFile — открытый WAV: формат и reader, стоящий на начале данных.
+type wavFile struct {
+	f          *os.File
+	data       io.Reader
+	sampleRate int
+	channels   int
+	// frames — число кадров (по сэмплу на канал) в данных
+	frames int64
+}
+
+// openWAV разбирает заголовок RIFF/WAVE. Поддерживается только 16‑битный
+// PCM — именно его принимает Vosk.
+func openWAV(path string) (*wavFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	w, err := readWAVHeader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s: %w", ErrUnsupportedAudio, path, err)
+	}
+	w.f = f
+	return w, nil
+}
+
+func readWAVHeader(f *os.File) (*wavFile, error) {
+	r := bufio.NewReader(f)
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, errors.New("not a WAV file")
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+
+	var (
+		w       wavFile
+		hasFmt  bool
+		chunkID [4]byte
+		size    uint32
+	)
+	for {
+		if _, err := io.ReadFull(r, chunkID[:]); err != nil {
+			return nil, errors.New("no data chunk")
+		}
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, errors.New("truncated chunk header")
+		}
+		switch string(chunkID[:]) {
+		case "fmt ":
+			var format struct {
+				AudioFormat   uint16
+				Channels      uint16
+				SampleRate    uint32
+				ByteRate      uint32
+				BlockAlign    uint16
+				BitsPerSample uint16
+			}
+			if size < 16 {
+				return nil, errors.New("truncated fmt chunk")
+			}
+			if err := binary.Read(r, binary.LittleEndian, &format); err != nil {
+				return nil, errors.New("truncated fmt chunk")
+			}
+			// 0xFFFE — WAVE_FORMAT_EXTENSIBLE, для PCM данные те же
+			if format.AudioFormat != 1 && format.AudioFormat != 0xFFFE || format.BitsPerSample != 16 {
+				return nil, fmt.Errorf("expected 16-bit PCM, got format %d with %d bits", format.AudioFormat, format.BitsPerSample)
+			}
+			if format.Channels == 0 || format.SampleRate == 0 {
+				return nil, errors.New("no channels or zero sample rate")
+			}
+			w.channels, w.sampleRate = int(format.Channels), int(format.SampleRate)
+			hasFmt = true
+			if _, err := r.Discard(int(size-16) + int(size%2)); err != nil {
+				return nil, errors.New("truncated fmt chunk")
+			}
+		case "data":
+			if !hasFmt {
+				return nil, errors.New("data chunk before fmt chunk")
+			}
+			w.frames = int64(size) / int64(2*w.channels)
+			w.data = io.LimitReader(r, int64(size))
+			return &w, nil
+		default:
+			// LIST, fact и прочие служебные куски пропускаем; размер
+			// выравнивается до чётного
+			if _, err := r.Discard(int(size) + int(size%2)); err != nil {
+				return nil, errors.New("no data chunk")
+			}
+		}
+	}
+}
+
+func (w *wavFile) Close() error {
+	return w.f.Close()
+}